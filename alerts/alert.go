@@ -2,64 +2,253 @@ package alerts
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mihaichiorean/monidog/parser"
+	"github.com/pkg/errors"
 )
 
-// Alert -
+// Alert counts logs in a sliding window and notifies when its threshold or
+// expression is crossed. It is safe for concurrent use
 type Alert struct {
-	name     string
-	window   time.Duration
-	bucketMS time.Duration
-	limit    int
-	buckets  map[time.Time]int
-	total    int
+	name  string
+	limit int
+	expr  *Expr
+
+	// mu guards everything below. The worker goroutine, snapshots, state queries
+	// and setters all go through it
+	mu       sync.Mutex
+	labels   map[string]string
+	silencer Silencer
+	onChange []func(Transition)
+	out      io.Writer
+	done     chan struct{}
+	lateness time.Duration
+	counter
+	conds []*condition
+	// ahead holds the logs newer than the watermark, counted once it passes them
+	ahead    []event
+	late     int
 	active   bool
-	cancel   func()
+	since    time.Time
+	notified time.Time
+	// transitions waiting to be printed and handed out once mu is released
+	pending []notification
+}
+
+// event is a log waiting to be counted, with the conditions it matched
+type event struct {
+	ts    time.Time
+	conds []*condition
+}
+
+// notification is a transition and the message printed for it
+type notification struct {
+	msg        string
+	transition Transition
+}
+
+// State is a point in time view of an alert, for status displays and health checks
+type State struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Active    bool              `json:"active"`
+	Count     int               `json:"count"`
+	Threshold int               `json:"threshold"`
+	Window    time.Duration     `json:"window"`
+	// Since is when the alert fired. Zero while not active
+	Since time.Time `json:"since"`
+	// DroppedLate is the number of logs that arrived after their window was evaluated
+	DroppedLate int `json:"dropped_late"`
+	// Expr and Values are only set for compound alerts
+	Expr   string             `json:"expr,omitempty"`
+	Values map[string]float64 `json:"values,omitempty"`
+}
+
+// FormatValues formats the condition values of a compound alert by name, e.g.
+// "all=20 errors=3"
+func FormatValues(values map[string]float64) string {
+	names := make([]string, 0, len(values))
+	for n := range values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = n + "=" + strconv.FormatFloat(values[n], 'f', -1, 64)
+	}
+	return strings.Join(parts, " ")
 }
 
 // NewAlert constructs a new alert with given name, window and alert threshold
 func NewAlert(name string, window time.Duration, trigger int) *Alert {
 	a := Alert{
-		name:     name,
-		window:   window,
-		bucketMS: window / 100,
-		limit:    trigger,
-		buckets:  map[time.Time]int{},
-		total:    0,
-		active:   false,
+		name:    name,
+		counter: newCounter(window),
+		limit:   trigger,
+		active:  false,
+		out:     os.Stdout,
 	}
 	return &a
 }
 
-// Start triggers this alert object to start listening for events
+// NewCompoundAlert constructs an alert that fires while expr is true. expr is
+// evaluated over the counts of the conditions in the window, see Expr
+func NewCompoundAlert(name string, window time.Duration, expr string, conds ...Condition) (*Alert, error) {
+	a := NewAlert(name, window, 0)
+	names := []string{}
+	seen := map[string]bool{}
+	for _, c := range conds {
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate condition %s", c.Name)
+		}
+		seen[c.Name] = true
+		cond, err := newCondition(c, window)
+		if err != nil {
+			return nil, err
+		}
+		a.conds = append(a.conds, cond)
+		names = append(names, c.Name)
+	}
+	e, err := CompileExpr(expr, names...)
+	if err != nil {
+		return nil, err
+	}
+	a.expr = e
+	return a, nil
+}
+
+// Name returns the name of this alert
+func (a *Alert) Name() string {
+	return a.name
+}
+
+// State returns the current state of the alert
+func (a *Alert) State() State {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := State{
+		Name:      a.name,
+		Labels:    a.labelsCopy(),
+		Active:    a.active,
+		Count:     a.total,
+		Threshold: a.limit,
+		Window:    a.window,
+		Since:     a.since,
+
+		DroppedLate: a.late,
+	}
+	if a.expr != nil {
+		s.Expr = a.expr.String()
+		s.Values = a.values()
+	}
+	return s
+}
+
+// Labels returns a copy of the labels attached to this alert
+func (a *Alert) Labels() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.labelsCopy()
+}
+
+func (a *Alert) labelsCopy() map[string]string {
+	m := make(map[string]string)
+	for k, v := range a.labels {
+		m[k] = v
+	}
+	return m
+}
+
+// SetLabels attaches labels to this alert. They are used to match silences
+func (a *Alert) SetLabels(labels map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.labels = labels
+}
+
+// SetSilencer sets the silencer consulted before notifying. Silenced alerts still
+// track their state, they just don't print transitions
+func (a *Alert) SetSilencer(s Silencer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.silencer = s
+}
+
+// OnTransition registers a function called every time the alert fires or recovers,
+// whether it is silenced or not. It is called from the goroutine processing logs,
+// without holding the alert lock, so it may query the alert
+func (a *Alert) OnTransition(f func(Transition)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onChange = append(a.onChange, f)
+}
+
+// SetOutput sets where transition messages are printed, stdout by default. nil
+// turns printing off, e.g. when a dashboard shows the transitions instead
+func (a *Alert) SetOutput(w io.Writer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.out = w
+}
+
+// SetPrecision changes how the alert counts the events in its window, see
+// Precision. It resets the counts so it should be called before starting
+func (a *Alert) SetPrecision(p Precision) error {
+	if err := p.validate(); err != nil {
+		return errors.Wrapf(err, "invalid precision of %s alert", a.name)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counter = newPreciseCounter(a.window, p)
+	for _, c := range a.conds {
+		c.counter = newPreciseCounter(c.window, p)
+	}
+	return nil
+}
+
+// SetAllowedLateness makes the alert tolerate logs arriving up to d late. The
+// window is evaluated as of the watermark, d before now, so a late log within
+// tolerance is counted in its window like an on-time one; older logs are counted
+// as dropped late. This delays firing and recovering by d
+func (a *Alert) SetAllowedLateness(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lateness = d
+}
+
+// Start triggers this alert object to start listening for events. The alert stops
+// on its own when in is closed
 func (a *Alert) Start(in <-chan parser.Log) error {
-	if a.cancel != nil {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done != nil {
 		return fmt.Errorf("%s alert already started", a.name)
 	}
 	done := make(chan struct{})
-	cancel := func() {
-		close(done)
-	}
-	a.cancel = cancel
+	a.done = done
+	every := a.bucketMS
 	go func() {
 		// cleanup old log counters every bucketMS l
-		t := time.NewTicker(a.bucketMS)
+		t := time.NewTicker(every)
 		defer t.Stop()
 		for {
 			select {
 			case log, ok := <-in:
 				if !ok {
 					// channel closed
-					a.Stop()
-					break
+					a.stop(done)
+					return
 				}
-				a.inc(log.Timestamp())
-				a.checkAndAlert()
+				a.observe(log)
 			case <-t.C:
-				a.clear()
-				a.checkAndAlert()
+				a.tick()
 			case <-done:
 				return
 			}
@@ -70,53 +259,195 @@ func (a *Alert) Start(in <-chan parser.Log) error {
 
 // Stop will cancel an alert
 func (a *Alert) Stop() error {
-	if a.cancel == nil {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done == nil {
 		return fmt.Errorf("cannot stop %s alert. not started yet", a.name)
 	}
-	a.cancel()
-	a.cancel = nil
+	close(a.done)
+	a.done = nil
 	return nil
 }
 
-func (a *Alert) clear() {
-	cutoff := time.Now().Add(-(a.window))
+// stop ends the run identified by done, unless it was already stopped. A run that
+// was stopped and replaced by a new Start is left alone
+func (a *Alert) stop(done chan struct{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done == done {
+		close(done)
+		a.done = nil
+	}
+}
+
+// running tells if the alert was started on its own
+func (a *Alert) running() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.done != nil
+}
+
+// observe counts a log and alerts if needed
+func (a *Alert) observe(l parser.Log) {
+	defer a.flush()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e := event{
+		ts: l.Timestamp(),
+	}
+	for _, c := range a.conds {
+		if c.matches(l) {
+			e.conds = append(e.conds, c)
+		}
+	}
+	a.clear()
+	if a.lateness > 0 && e.ts.After(a.watermark()) {
+		a.ahead = append(a.ahead, e)
+	} else {
+		a.count(e, a.watermark())
+	}
+	a.checkAndAlert()
+}
 
-	old := []time.Time{}
-	// cleanup
-	for k, _ := range a.buckets {
-		if k.Unix() < cutoff.Unix() {
-			old = append(old, k)
+// tick drops expired counts and alerts if needed
+func (a *Alert) tick() {
+	defer a.flush()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clear()
+	a.checkAndAlert()
+}
+
+// watermark is the time up to which the window is evaluated
+func (a *Alert) watermark() time.Time {
+	return time.Now().Add(-(a.lateness))
+}
+
+// count adds an event to the window ending at wm, or drops it if it is too old
+func (a *Alert) count(e event, wm time.Time) {
+	if e.ts.Before(wm.Add(-(a.window))) {
+		a.late++
+		return
+	}
+	a.add(e.ts, wm)
+	for _, c := range e.conds {
+		c.add(e.ts, wm)
+	}
+}
+
+func (a *Alert) clear() {
+	now := time.Now()
+	wm := a.watermark()
+	// count the events the watermark passed
+	ahead := a.ahead[:0]
+	for _, e := range a.ahead {
+		if e.ts.After(wm) {
+			ahead = append(ahead, e)
+			continue
 		}
+		a.count(e, wm)
 	}
+	a.ahead = ahead
 
-	for _, v := range old {
-		count, _ := a.buckets[v]
-		delete(a.buckets, v)
-		a.total -= count
+	a.drop(wm)
+	for _, c := range a.conds {
+		c.drop(wm)
 	}
-	if a.active == true && a.total < a.limit {
-		fmt.Printf("%s: recovered\n", a.name)
+	if a.active == true && !a.firing() {
+		t := a.transition(Recovered, now)
+		t.Duration = now.Sub(a.since)
 		a.active = false
+		a.since = time.Time{}
+		a.notify(fmt.Sprintf("%s: recovered", a.name), t)
 	}
 }
 
 func (a *Alert) inc(ts time.Time) {
 	a.clear()
-	cutoff := time.Now().Add(-(a.window))
-	if ts.Before(cutoff) {
-		return
+	a.count(event{ts: ts}, a.watermark())
+}
+
+// firing tells if the alert condition currently holds
+func (a *Alert) firing() bool {
+	if a.expr != nil {
+		return a.expr.True(a.values())
 	}
-	k := ts.Truncate(a.bucketMS)
-	if _, ok := a.buckets[k]; !ok {
-		a.buckets[k] = 0
+	return a.total >= a.limit
+}
+
+// values returns the counts of the conditions by name
+func (a *Alert) values() map[string]float64 {
+	values := make(map[string]float64, len(a.conds))
+	for _, c := range a.conds {
+		values[c.Name] = float64(c.total)
 	}
-	a.buckets[k] += 1
-	a.total += 1
+	return values
 }
 
 func (a *Alert) checkAndAlert() {
-	if a.total >= a.limit && a.active == false {
+	if a.firing() && a.active == false {
 		a.active = true
-		fmt.Printf("!!!! %s:  alert triggered !!!!\n", a.name)
+		a.since = time.Now()
+		a.notify(fmt.Sprintf("!!!! %s:  alert triggered !!!!", a.name), a.transition(Fired, a.since))
+	}
+}
+
+func (a *Alert) transition(e Event, at time.Time) Transition {
+	t := Transition{
+		Name:      a.name,
+		Labels:    a.labelsCopy(),
+		Event:     e,
+		At:        at,
+		Value:     a.total,
+		Threshold: a.limit,
+	}
+	if a.expr != nil {
+		t.Expr = a.expr.String()
+		t.Values = a.values()
+	}
+	return t
+}
+
+// notify queues a transition. It is checked against the silences, printed and
+// handed to the transition functions by flush
+func (a *Alert) notify(msg string, t Transition) {
+	a.pending = append(a.pending, notification{msg: msg, transition: t})
+}
+
+// flush marks the queued transitions that a silence matches, prints the others
+// and hands all of them to the registered transition functions. It must be
+// called without holding mu: silencers may read files, which shouldn't hold up
+// the alert
+func (a *Alert) flush() {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = nil
+	onChange := a.onChange
+	out := a.out
+	silencer := a.silencer
+	a.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	for i := range pending {
+		t := &pending[i].transition
+		t.Silenced = silencer != nil && silencer.Silenced(t.Name, t.Labels, t.At)
+	}
+	a.mu.Lock()
+	for _, n := range pending {
+		if !n.transition.Silenced && n.transition.At.After(a.notified) {
+			a.notified = n.transition.At
+		}
+	}
+	a.mu.Unlock()
+
+	for _, n := range pending {
+		if !n.transition.Silenced && out != nil {
+			fmt.Fprintln(out, n.msg)
+		}
+		for _, f := range onChange {
+			f(n.transition)
+		}
 	}
 }
@@ -1,6 +1,8 @@
 package alerts
 
 import (
+	"bytes"
+	"sync"
 	"testing"
 	"time"
 
@@ -22,3 +24,154 @@ func Test_Start(t *testing.T) {
 	ch <- l
 	assert.NoError(t, a.Stop())
 }
+
+func Test_Start_closed(t *testing.T) {
+	a := NewAlert("test", 1*time.Second, 1)
+	ch := make(chan parser.Log)
+	assert.NoError(t, a.Start(ch))
+	assert.Error(t, a.Start(ch))
+	close(ch)
+	for a.running() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Error(t, a.Stop())
+	// can be started again
+	assert.NoError(t, a.Start(make(chan parser.Log)))
+	assert.NoError(t, a.Stop())
+}
+
+func Test_State(t *testing.T) {
+	a := NewAlert("test", time.Minute, 2)
+	a.SetLabels(map[string]string{"team": "web"})
+	s := a.State()
+	assert.Equal(t, State{
+		Name:      "test",
+		Labels:    map[string]string{"team": "web"},
+		Threshold: 2,
+		Window:    time.Minute,
+	}, s)
+
+	before := time.Now()
+	a.inc(time.Now())
+	a.inc(time.Now())
+	a.checkAndAlert()
+	s = a.State()
+	assert.True(t, s.Active)
+	assert.Equal(t, 2, s.Count)
+	assert.False(t, s.Since.Before(before))
+	assert.Empty(t, s.Expr)
+
+	c, err := NewCompoundAlert("compound", time.Minute, "all > 1", Condition{Name: "all"})
+	assert.NoError(t, err)
+	s = c.State()
+	assert.Equal(t, "all > 1", s.Expr)
+	assert.Equal(t, map[string]float64{"all": 0}, s.Values)
+}
+
+func Test_Alert_concurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a := NewAlert("test", 10*time.Millisecond, 5)
+	a.OnTransition(func(Transition) {
+		// listeners run without the lock held
+		a.State()
+	})
+	ch := make(chan parser.Log)
+	assert.NoError(t, a.Start(ch))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			l := mocks.NewMockLog(ctrl)
+			l.EXPECT().Timestamp().Return(time.Now())
+			ch <- l
+		}
+		close(stop)
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				a.State()
+				a.Snapshot()
+				a.Labels()
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				a.SetLabels(map[string]string{"run": "x"})
+				a.running()
+			}
+		}
+	}()
+	wg.Wait()
+	close(ch)
+	for a.running() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Error(t, a.Stop())
+}
+
+func Test_AllowedLateness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	send := func(a *Alert, ts time.Time) {
+		l := mocks.NewMockLog(ctrl)
+		l.EXPECT().Timestamp().Return(ts)
+		a.observe(l)
+	}
+
+	a := NewAlert("test", time.Second, 2)
+	a.SetAllowedLateness(200 * time.Millisecond)
+	now := time.Now()
+	// ahead of the watermark, not counted yet
+	send(a, now)
+	assert.Equal(t, 0, a.State().Count)
+	assert.Len(t, a.ahead, 1)
+	// late, within tolerance
+	send(a, now.Add(-500*time.Millisecond))
+	assert.Equal(t, 1, a.State().Count)
+	// older than the window at the watermark
+	send(a, now.Add(-1300*time.Millisecond))
+	assert.Equal(t, 1, a.State().DroppedLate)
+	assert.False(t, a.State().Active)
+
+	// the watermark passes the first log
+	a.SetAllowedLateness(0)
+	a.tick()
+	assert.Empty(t, a.ahead)
+	assert.Equal(t, 2, a.State().Count)
+	assert.True(t, a.State().Active)
+}
+
+func Test_SetOutput(t *testing.T) {
+	var out bytes.Buffer
+	a := NewAlert("test", time.Minute, 1)
+	a.SetOutput(&out)
+	a.inc(time.Now())
+	a.checkAndAlert()
+	a.flush()
+	assert.Contains(t, out.String(), "test:  alert triggered")
+
+	b := NewAlert("quiet", time.Minute, 1)
+	b.SetOutput(nil)
+	transitions := 0
+	b.OnTransition(func(Transition) { transitions++ })
+	b.inc(time.Now())
+	b.checkAndAlert()
+	b.flush()
+	assert.Equal(t, 1, transitions)
+}
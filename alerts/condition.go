@@ -0,0 +1,99 @@
+package alerts
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/pkg/errors"
+)
+
+// Condition is a named count of the logs matching a filter over the alert window.
+// An empty filter matches every log
+type Condition struct {
+	Name string `json:"name"`
+	// Path is a regular expression matched against the requested resource
+	Path string `json:"path,omitempty"`
+	// Status is an exact response status such as 404, or a class such as 5xx
+	Status string `json:"status,omitempty"`
+	// SlowerThan is a duration such as 500ms that the request time of a log,
+	// parser.FieldRequestTime, must exceed. Logs without one don't match. Over
+	// a count of all requests this tells a percentile: more than 1% of them
+	// slower than 500ms means the p99 latency is above 500ms
+	SlowerThan string `json:"slower_than,omitempty"`
+}
+
+type condition struct {
+	Condition
+	path   *regexp.Regexp
+	status int
+	class  bool
+	slower float64
+	counter
+}
+
+func newCondition(c Condition, window time.Duration) (*condition, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("condition name is required")
+	}
+	if !isIdent(c.Name) {
+		return nil, fmt.Errorf("invalid condition name %q. expected letters, digits and _ starting with a letter or _, other than and, or and not", c.Name)
+	}
+	cond := condition{
+		Condition: c,
+		counter:   newCounter(window),
+	}
+	if c.Path != "" {
+		re, err := regexp.Compile(c.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid path of %s condition", c.Name)
+		}
+		cond.path = re
+	}
+	if c.Status != "" {
+		s := strings.ToLower(c.Status)
+		if len(s) == 3 && strings.HasSuffix(s, "xx") {
+			cond.class = true
+			s = s[:1]
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q of %s condition", c.Status, c.Name)
+		}
+		cond.status = v
+	}
+	if c.SlowerThan != "" {
+		d, err := time.ParseDuration(c.SlowerThan)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid slower_than %q of %s condition", c.SlowerThan, c.Name)
+		}
+		cond.slower = d.Seconds()
+	}
+	return &cond, nil
+}
+
+func (c *condition) matches(l parser.Log) bool {
+	if c.path != nil && !c.path.MatchString(l.Resource()) {
+		return false
+	}
+	if c.slower > 0 {
+		t, ok := parser.Field(l, parser.FieldRequestTime)
+		if s, isSeconds := t.(float64); !ok || !isSeconds || s <= c.slower {
+			return false
+		}
+	}
+	if c.Status == "" {
+		return true
+	}
+	h, ok := l.(parser.HTTPLog)
+	if !ok {
+		return false
+	}
+	if c.class {
+		return h.Status()/100 == c.status
+	}
+	return h.Status() == c.status
+}
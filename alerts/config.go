@@ -0,0 +1,110 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config declares alert rules, usually loaded from a JSON file:
+//
+//	{"alerts": [
+//	  {"name": "high traffic", "window": "2m", "threshold": 10},
+//	  {"name": "errors", "window": "1m", "labels": {"team": "web"},
+//	   "expr": "requests > 100 && errors / requests > 0.1",
+//	   "conditions": [{"name": "requests"}, {"name": "errors", "status": "5xx"}]},
+//	  {"name": "bursts", "window": "500ms", "threshold": 50,
+//	   "precision": {"exact": true, "max_events": 1000}}
+//	]}
+type Config struct {
+	Alerts []RuleConfig `json:"alerts"`
+}
+
+// RuleConfig declares a single alert. Rules with an expression are compound
+// alerts, the others fire when the number of logs in the window reaches Threshold
+type RuleConfig struct {
+	Name       string            `json:"name"`
+	Window     string            `json:"window"`
+	Threshold  int               `json:"threshold,omitempty"`
+	Expr       string            `json:"expr,omitempty"`
+	Conditions []Condition       `json:"conditions,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Precision  Precision         `json:"precision,omitempty"`
+	// AllowedLateness is how late logs may arrive and still be counted, e.g. "5s"
+	AllowedLateness string `json:"allowed_lateness,omitempty"`
+}
+
+// LoadConfig reads alert rules from a JSON file
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read alerts config %s", path)
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode alerts config %s", path)
+	}
+	return &c, nil
+}
+
+// Build constructs the alert declared by the rule
+func (r RuleConfig) Build() (*Alert, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("alert name is required")
+	}
+	window, err := time.ParseDuration(r.Window)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid window of %s alert", r.Name)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("window of %s alert must be positive", r.Name)
+	}
+
+	var a *Alert
+	if r.Expr != "" {
+		a, err = NewCompoundAlert(r.Name, window, r.Expr, r.Conditions...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s alert", r.Name)
+		}
+	} else {
+		if r.Threshold <= 0 {
+			return nil, fmt.Errorf("%s alert needs a positive threshold or an expression", r.Name)
+		}
+		a = NewAlert(r.Name, window, r.Threshold)
+	}
+	a.SetLabels(r.Labels)
+	if r.AllowedLateness != "" {
+		d, err := time.ParseDuration(r.AllowedLateness)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid allowed lateness %q of %s alert", r.AllowedLateness, r.Name)
+		}
+		a.SetAllowedLateness(d)
+	}
+	if r.Precision != (Precision{}) {
+		if err := a.SetPrecision(r.Precision); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Build constructs all the alerts declared in the config
+func (c *Config) Build() ([]*Alert, error) {
+	alerts := make([]*Alert, 0, len(c.Alerts))
+	names := map[string]bool{}
+	for _, r := range c.Alerts {
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate alert %s", r.Name)
+		}
+		names[r.Name] = true
+		a, err := r.Build()
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
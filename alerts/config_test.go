@@ -0,0 +1,168 @@
+package alerts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/mihaichiorean/monidog/mocks"
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `{"alerts": [
+  {"name": "high traffic", "window": "2m", "threshold": 10},
+  {"name": "errors", "window": "1m", "labels": {"team": "web"},
+   "expr": "requests >= 4 && errors / requests > 0.5",
+   "conditions": [{"name": "requests"}, {"name": "errors", "path": "^/api/", "status": "5xx"}]}
+]}`
+
+func Test_LoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "alerts.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testConfig), 0644))
+
+	c, err := LoadConfig(path)
+	assert.NoError(t, err)
+	alerts, err := c.Build()
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 2)
+	assert.Equal(t, "high traffic", alerts[0].Name())
+	assert.Equal(t, 10, alerts[0].limit)
+	assert.Nil(t, alerts[0].expr)
+	assert.Equal(t, "errors", alerts[1].Name())
+	assert.Equal(t, map[string]string{"team": "web"}, alerts[1].Labels())
+	assert.Len(t, alerts[1].conds, 2)
+
+	_, err = LoadConfig(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func Test_RuleConfig_invalid(t *testing.T) {
+	for _, r := range []RuleConfig{
+		{Window: "1m", Threshold: 1},
+		{Name: "a", Window: "soon", Threshold: 1},
+		{Name: "a", Window: "-1m", Threshold: 1},
+		{Name: "a", Window: "1m"},
+		{Name: "a", Window: "1m", Expr: "x > 1"},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Name: "x", Status: "5x"}}},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Name: "x", Path: "("}}},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Path: "/"}}},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Name: "x"}, {Name: "x", Status: "5xx"}}},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Name: "x", SlowerThan: "slow"}}},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Name: "x"}, {Name: "5xx", Status: "5xx"}}},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Name: "x"}, {Name: "Or"}}},
+		{Name: "a", Window: "1m", Expr: "x > 1", Conditions: []Condition{{Name: "x"}, {Name: "api-errors"}}},
+	} {
+		_, err := r.Build()
+		assert.Error(t, err, "%+v", r)
+	}
+
+	c := Config{Alerts: []RuleConfig{
+		{Name: "a", Window: "1m", Threshold: 1},
+		{Name: "a", Window: "1m", Threshold: 2},
+	}}
+	_, err := c.Build()
+	assert.Error(t, err)
+}
+
+func Test_CompoundAlert(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a, err := NewCompoundAlert("errors", time.Minute, "requests >= 4 && errors / requests > 0.5",
+		Condition{Name: "requests"},
+		Condition{Name: "errors", Path: "^/api/", Status: "5xx"},
+	)
+	assert.NoError(t, err)
+	transitions := []Transition{}
+	a.OnTransition(func(t Transition) {
+		transitions = append(transitions, t)
+	})
+
+	send := func(resource string, status int) {
+		l := mocks.NewMockHTTPLog(ctrl)
+		l.EXPECT().Timestamp().Return(time.Now())
+		l.EXPECT().Resource().Return(resource).AnyTimes()
+		l.EXPECT().Status().Return(status).AnyTimes()
+		a.observe(l)
+	}
+	send("/api/users", 500)
+	send("/api/users", 503)
+	send("/pages", 500)
+	assert.False(t, a.active)
+	send("/api/users", 200)
+	// 2 errors out of 4
+	assert.False(t, a.active)
+	send("/api/users", 502)
+	assert.True(t, a.active)
+	assert.Len(t, transitions, 1)
+	assert.Equal(t, 5, transitions[0].Value)
+	assert.Equal(t, "requests >= 4 && errors / requests > 0.5", transitions[0].Expr)
+	assert.Equal(t, map[string]float64{"requests": 5, "errors": 3}, transitions[0].Values)
+
+	// the state of the conditions is carried in snapshots
+	s := a.Snapshot()
+	assert.Len(t, s.Conditions["errors"], len(a.conds[1].buckets))
+	b, err := NewCompoundAlert("errors", time.Minute, "requests >= 4 && errors / requests > 0.5",
+		Condition{Name: "requests"},
+		Condition{Name: "errors", Path: "^/api/", Status: "5xx"},
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, b.Restore(s))
+	assert.Equal(t, map[string]float64{"requests": 5, "errors": 3}, b.values())
+
+	// the errors leave the window
+	for _, c := range a.conds {
+		old := map[time.Time]int{}
+		for ts, n := range c.buckets {
+			old[ts.Add(-2*time.Minute)] = n
+		}
+		c.buckets = old
+	}
+	a.tick()
+	assert.False(t, a.active)
+	assert.Len(t, transitions, 2)
+}
+
+func Test_CompoundAlert_slowerThan(t *testing.T) {
+	p, err := parser.NewNginxParser(`$time_iso8601 "$request" $status $request_time`)
+	assert.NoError(t, err)
+	// the p99 latency is above 500ms
+	a, err := NewCompoundAlert("latency", time.Minute, "slow / requests > 0.01",
+		Condition{Name: "requests"},
+		Condition{Name: "slow", SlowerThan: "500ms"},
+	)
+	assert.NoError(t, err)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, rt := range []string{"0.100", "0.500", "0.750"} {
+		l, err := p.Parse(now + ` "GET / HTTP/1.1" 200 ` + rt)
+		assert.NoError(t, err)
+		a.observe(l)
+	}
+	assert.Equal(t, map[string]float64{"requests": 3, "slow": 1}, a.values())
+	assert.True(t, a.active)
+
+	// so are the latencies of JSON logs, in their unit
+	j, err := parser.ParseLogFormat("json:latency=duration_ms,latency_unit=ms")
+	assert.NoError(t, err)
+	l, err := j.Parse(`{"time":"` + now + `","path":"/","duration_ms":600}`)
+	assert.NoError(t, err)
+	a.observe(l)
+	assert.Equal(t, map[string]float64{"requests": 4, "slow": 2}, a.values())
+
+	// logs without a request time are never slow
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockLog(ctrl)
+	m.EXPECT().Timestamp().Return(time.Now())
+	m.EXPECT().Resource().Return("/").AnyTimes()
+	a.observe(m)
+	assert.Equal(t, map[string]float64{"requests": 5, "slow": 2}, a.values())
+}
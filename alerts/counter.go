@@ -0,0 +1,201 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultBuckets is the number of buckets a window is split into by default
+const DefaultBuckets = 100
+
+// DefaultMaxEvents bounds the memory of exact windows by default
+const DefaultMaxEvents = 10000
+
+// Precision selects how the events in an alert window are counted.
+//
+// Bucketed windows (the default) group events in Buckets fixed size buckets of
+// window/Buckets each. A bucket is counted while its start is inside the window,
+// so the count at time now is never more than the exact count over
+// [now-window, now] and never less than the exact count over
+// [now-window+window/Buckets, now]: it lags by at most one bucket. Memory is
+// bounded by Buckets.
+//
+// Exact windows keep the timestamp of every event in a ring buffer of at most
+// MaxEvents entries, so the count is exactly the number of events in
+// [now-window, now] as long as there are fewer than MaxEvents of them. Past that
+// the oldest timestamps are evicted and the count stays at MaxEvents, which is
+// still exact for comparing against thresholds up to MaxEvents
+type Precision struct {
+	Buckets   int  `json:"buckets,omitempty"`
+	Exact     bool `json:"exact,omitempty"`
+	MaxEvents int  `json:"max_events,omitempty"`
+}
+
+func (p Precision) validate() error {
+	if p.Buckets < 0 {
+		return fmt.Errorf("number of buckets must be positive")
+	}
+	if p.MaxEvents < 0 {
+		return fmt.Errorf("max events must be positive")
+	}
+	return nil
+}
+
+// counter counts events in a sliding window, either in fixed size buckets or
+// exactly with a ring buffer of timestamps. See Precision
+type counter struct {
+	window   time.Duration
+	bucketMS time.Duration
+	buckets  map[time.Time]int
+	total    int
+
+	exact bool
+	// ring holds event timestamps sorted oldest first, starting at head
+	ring []time.Time
+	head int
+	size int
+}
+
+func newCounter(window time.Duration) counter {
+	return newPreciseCounter(window, Precision{})
+}
+
+func newPreciseCounter(window time.Duration, p Precision) counter {
+	if p.Buckets == 0 {
+		p.Buckets = DefaultBuckets
+	}
+	c := counter{
+		window:   window,
+		bucketMS: window / time.Duration(p.Buckets),
+		buckets:  map[time.Time]int{},
+		exact:    p.Exact,
+	}
+	if c.bucketMS <= 0 {
+		c.bucketMS = 1
+	}
+	if p.Exact {
+		if p.MaxEvents == 0 {
+			p.MaxEvents = DefaultMaxEvents
+		}
+		c.ring = make([]time.Time, p.MaxEvents)
+	}
+	return c
+}
+
+// at returns the ith oldest timestamp of the ring
+func (c *counter) at(i int) time.Time {
+	return c.ring[(c.head+i)%len(c.ring)]
+}
+
+// drop removes the events that fell out of the window
+func (c *counter) drop(now time.Time) {
+	cutoff := now.Add(-(c.window))
+
+	if c.exact {
+		for c.size > 0 && c.at(0).Before(cutoff) {
+			c.head = (c.head + 1) % len(c.ring)
+			c.size--
+		}
+		c.total = c.size
+		return
+	}
+
+	old := []time.Time{}
+	// cleanup
+	for k, _ := range c.buckets {
+		if k.Before(cutoff) {
+			old = append(old, k)
+		}
+	}
+
+	for _, v := range old {
+		count, _ := c.buckets[v]
+		delete(c.buckets, v)
+		c.total -= count
+	}
+}
+
+// add counts an event that happened at ts, unless it is already out of the window
+func (c *counter) add(ts time.Time, now time.Time) {
+	cutoff := now.Add(-(c.window))
+	if ts.Before(cutoff) {
+		return
+	}
+	if c.exact {
+		c.push(ts)
+		return
+	}
+	k := ts.Truncate(c.bucketMS)
+	if _, ok := c.buckets[k]; !ok {
+		c.buckets[k] = 0
+	}
+	c.buckets[k] += 1
+	c.total += 1
+}
+
+// push inserts ts in the ring keeping it sorted. Logs arrive mostly in order so
+// this rarely moves more than a few entries. When the ring is full the oldest
+// timestamp is evicted
+func (c *counter) push(ts time.Time) {
+	n := len(c.ring)
+	if c.size == n {
+		if ts.Before(c.at(0)) {
+			// older than everything we keep
+			return
+		}
+		c.head = (c.head + 1) % n
+		c.size--
+	}
+	i := c.size
+	c.ring[(c.head+i)%n] = ts
+	c.size++
+	for ; i > 0 && c.at(i-1).After(ts); i-- {
+		c.ring[(c.head+i)%n] = c.at(i - 1)
+		c.ring[(c.head+i-1)%n] = ts
+	}
+	c.total = c.size
+}
+
+// counts returns the counted events as a list, for snapshots
+func (c *counter) counts() []BucketCount {
+	if c.exact {
+		counts := []BucketCount{}
+		for i := 0; i < c.size; i++ {
+			ts := c.at(i)
+			if l := len(counts); l > 0 && counts[l-1].Ts.Equal(ts) {
+				counts[l-1].Count++
+				continue
+			}
+			counts = append(counts, BucketCount{Ts: ts, Count: 1})
+		}
+		return counts
+	}
+	counts := make([]BucketCount, 0, len(c.buckets))
+	for ts, n := range c.buckets {
+		counts = append(counts, BucketCount{Ts: ts, Count: n})
+	}
+	return counts
+}
+
+// restore replaces the counts with the given ones, dropping the ones out of the window
+func (c *counter) restore(counts []BucketCount, now time.Time) {
+	cutoff := now.Add(-(c.window))
+	c.buckets = map[time.Time]int{}
+	c.total = 0
+	c.head = 0
+	c.size = 0
+	for _, b := range counts {
+		if b.Ts.Before(cutoff) {
+			continue
+		}
+		if c.exact {
+			for i := 0; i < b.Count; i++ {
+				c.push(b.Ts)
+			}
+			continue
+		}
+		// bucket size may have changed between runs
+		c.buckets[b.Ts.Truncate(c.bucketMS)] += b.Count
+		c.total += b.Count
+	}
+}
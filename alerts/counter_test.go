@@ -0,0 +1,136 @@
+package alerts
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oracle counts the events in [from, to] by brute force
+func oracle(events []time.Time, from, to time.Time) int {
+	n := 0
+	for _, ts := range events {
+		if !ts.Before(from) && !ts.After(to) {
+			n++
+		}
+	}
+	return n
+}
+
+// stream generates events every few ms, slightly out of order, and calls f with
+// each event and the time it is observed at
+func stream(seed int64, n int, f func(ts, now time.Time)) {
+	r := rand.New(rand.NewSource(seed))
+	now := time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		now = now.Add(time.Duration(r.Intn(5000)) * time.Microsecond)
+		// up to 2ms late
+		ts := now.Add(-time.Duration(r.Intn(2000)) * time.Microsecond)
+		f(ts, now)
+	}
+}
+
+func Test_counter_bucketed_oracle(t *testing.T) {
+	for _, window := range []time.Duration{50 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second} {
+		for _, buckets := range []int{1, 10, 100} {
+			c := newPreciseCounter(window, Precision{Buckets: buckets})
+			bucket := window / time.Duration(buckets)
+			events := []time.Time{}
+			stream(int64(buckets), 2000, func(ts, now time.Time) {
+				c.drop(now)
+				c.add(ts, now)
+				if !ts.Before(now.Add(-window)) {
+					events = append(events, ts)
+				}
+				c.drop(now)
+				upper := oracle(events, now.Add(-window), now)
+				lower := oracle(events, now.Add(-window+bucket), now)
+				if c.total > upper || c.total < lower {
+					t.Fatalf("window %s, %d buckets: count %d not in [%d, %d]", window, buckets, c.total, lower, upper)
+				}
+			})
+			assert.True(t, len(c.buckets) <= buckets+1)
+		}
+	}
+}
+
+func Test_counter_exact_oracle(t *testing.T) {
+	for _, window := range []time.Duration{time.Millisecond, 50 * time.Millisecond, 2 * time.Second} {
+		c := newPreciseCounter(window, Precision{Exact: true, MaxEvents: 5000})
+		events := []time.Time{}
+		stream(42, 2000, func(ts, now time.Time) {
+			c.drop(now)
+			c.add(ts, now)
+			if !ts.Before(now.Add(-window)) {
+				events = append(events, ts)
+			}
+			c.drop(now)
+			want := oracle(events, now.Add(-window), now)
+			if c.total != want {
+				t.Fatalf("window %s: count %d, want %d", window, c.total, want)
+			}
+		})
+	}
+}
+
+func Test_counter_exact_bounded(t *testing.T) {
+	window := time.Second
+	c := newPreciseCounter(window, Precision{Exact: true, MaxEvents: 50})
+	events := []time.Time{}
+	stream(7, 2000, func(ts, now time.Time) {
+		c.drop(now)
+		c.add(ts, now)
+		if !ts.Before(now.Add(-window)) {
+			events = append(events, ts)
+		}
+		c.drop(now)
+		want := oracle(events, now.Add(-window), now)
+		if want > 50 {
+			want = 50
+		}
+		if c.total != want {
+			t.Fatalf("count %d, want %d", c.total, want)
+		}
+	})
+	assert.Len(t, c.ring, 50)
+}
+
+func Test_counter_exact_snapshot(t *testing.T) {
+	now := time.Now()
+	c := newPreciseCounter(time.Minute, Precision{Exact: true, MaxEvents: 10})
+	c.add(now.Add(-time.Second), now)
+	c.add(now.Add(-time.Second), now)
+	c.add(now, now)
+	counts := c.counts()
+	assert.Equal(t, []BucketCount{
+		{Ts: now.Add(-time.Second), Count: 2},
+		{Ts: now, Count: 1},
+	}, counts)
+
+	b := newPreciseCounter(time.Minute, Precision{Exact: true, MaxEvents: 10})
+	b.restore(counts, now)
+	assert.Equal(t, 3, b.total)
+}
+
+func Test_SetPrecision(t *testing.T) {
+	a := NewAlert("test", 500*time.Millisecond, 3)
+	assert.Error(t, a.SetPrecision(Precision{Buckets: -1}))
+	assert.NoError(t, a.SetPrecision(Precision{Exact: true, MaxEvents: 3}))
+	now := time.Now()
+	a.inc(now.Add(-600 * time.Millisecond))
+	a.inc(now.Add(-100 * time.Millisecond))
+	a.inc(now)
+	a.checkAndAlert()
+	assert.False(t, a.active)
+	a.inc(now)
+	a.checkAndAlert()
+	assert.True(t, a.active)
+	assert.Equal(t, 3, a.State().Count)
+
+	// sub second windows with fine buckets
+	b := NewAlert("test", 100*time.Millisecond, 2)
+	assert.NoError(t, b.SetPrecision(Precision{Buckets: 1000}))
+	assert.Equal(t, 100*time.Microsecond, b.bucketMS)
+}
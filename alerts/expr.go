@@ -0,0 +1,349 @@
+package alerts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled alert expression. It combines the windowed counts of named
+// conditions with arithmetic (+ - * /), comparisons (< <= > >= == !=) and boolean
+// operators (&& || ! or and, or, not), e.g.
+//
+//	requests > 100 && errors / requests > 0.1
+//
+// Comparisons and boolean operators evaluate to 1 or 0. Dividing by zero gives 0,
+// so ratios over an empty window don't fire
+type Expr struct {
+	src  string
+	root node
+}
+
+// CompileExpr parses an expression. Identifiers must be one of names
+func CompileExpr(src string, names ...string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, n := range names {
+		known[n] = true
+	}
+	p := exprParser{
+		tokens: tokens,
+		known:  known,
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %s", src, err.Error())
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q", src, p.tokens[p.pos].text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval evaluates the expression with the given values of the identifiers
+func (e *Expr) Eval(values map[string]float64) float64 {
+	return e.root.eval(values)
+}
+
+// True tells if the expression evaluates to a non zero value
+func (e *Expr) True(values map[string]float64) bool {
+	return e.Eval(values) != 0
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+type node interface {
+	eval(values map[string]float64) float64
+}
+
+type number float64
+
+func (n number) eval(map[string]float64) float64 {
+	return float64(n)
+}
+
+type ident string
+
+func (i ident) eval(values map[string]float64) float64 {
+	return values[string(i)]
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+func (u unary) eval(values map[string]float64) float64 {
+	v := u.x.eval(values)
+	if u.op == "-" {
+		return -v
+	}
+	// "!"
+	return boolean(v == 0)
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+func (b binary) eval(values map[string]float64) float64 {
+	x := b.x.eval(values)
+	// short circuit boolean operators
+	switch b.op {
+	case "&&":
+		return boolean(x != 0 && b.y.eval(values) != 0)
+	case "||":
+		return boolean(x != 0 || b.y.eval(values) != 0)
+	}
+	y := b.y.eval(values)
+	switch b.op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y == 0 {
+			return 0
+		}
+		return x / y
+	case "<":
+		return boolean(x < y)
+	case "<=":
+		return boolean(x <= y)
+	case ">":
+		return boolean(x > y)
+	case ">=":
+		return boolean(x >= y)
+	case "==":
+		return boolean(x == y)
+	case "!=":
+		return boolean(x != y)
+	}
+	return 0
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// keywords are spelled-out aliases of the boolean operators
+var keywords = map[string]string{
+	"and": "&&",
+	"or":  "||",
+	"not": "!",
+}
+
+// isIdent tells if name can be referenced in an expression: a letter or _
+// followed by letters, digits or _, and not a keyword
+func isIdent(name string) bool {
+	if _, ok := keywords[strings.ToLower(name)]; ok {
+		return false
+	}
+	for i, c := range name {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func tokenize(src string) ([]token, error) {
+	tokens := []token{}
+	r := []rune(src)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(r) && (unicode.IsDigit(r[j]) || r[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, string(r[i:j])})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_') {
+				j++
+			}
+			word := string(r[i:j])
+			if op, ok := keywords[strings.ToLower(word)]; ok {
+				tokens = append(tokens, token{tokOp, op})
+			} else {
+				tokens = append(tokens, token{tokIdent, word})
+			}
+			i = j
+		default:
+			if i+1 < len(r) {
+				two := string(r[i : i+2])
+				switch two {
+				case "&&", "||", "<=", ">=", "==", "!=":
+					tokens = append(tokens, token{tokOp, two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/<>!()", c) {
+				return nil, fmt.Errorf("invalid expression %q: unexpected character %q", src, c)
+			}
+			tokens = append(tokens, token{tokOp, string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// exprParser is a recursive descent parser, one method per precedence level
+type exprParser struct {
+	tokens []token
+	pos    int
+	known  map[string]bool
+}
+
+func (p *exprParser) peek(ops ...string) (string, bool) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// binaryLevel parses a left associative chain of the given operators
+func (p *exprParser) binaryLevel(next func() (node, error), ops ...string) (node, error) {
+	x, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peek(ops...)
+		if !ok {
+			return x, nil
+		}
+		p.pos++
+		y, err := next()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) or() (node, error) {
+	return p.binaryLevel(p.and, "||")
+}
+
+func (p *exprParser) and() (node, error) {
+	return p.binaryLevel(p.not, "&&")
+}
+
+func (p *exprParser) not() (node, error) {
+	if _, ok := p.peek("!"); ok {
+		p.pos++
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "!", x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *exprParser) comparison() (node, error) {
+	x, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.peek("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return x, nil
+	}
+	p.pos++
+	y, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	return binary{op: op, x: x, y: y}, nil
+}
+
+func (p *exprParser) sum() (node, error) {
+	return p.binaryLevel(p.product, "+", "-")
+}
+
+func (p *exprParser) product() (node, error) {
+	return p.binaryLevel(p.negation, "*", "/")
+}
+
+func (p *exprParser) negation() (node, error) {
+	if _, ok := p.peek("-"); ok {
+		p.pos++
+		x, err := p.negation()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "-", x: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return number(v), nil
+	case tokIdent:
+		if !p.known[t.text] {
+			return nil, fmt.Errorf("unknown condition %q", t.text)
+		}
+		return ident(t.text), nil
+	}
+	if t.text != "(" {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	x, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.peek(")"); !ok {
+		return nil, fmt.Errorf("missing )")
+	}
+	p.pos++
+	return x, nil
+}
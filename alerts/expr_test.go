@@ -0,0 +1,63 @@
+package alerts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CompileExpr(t *testing.T) {
+	values := map[string]float64{"requests": 200, "errors": 30, "slow": 0}
+	cases := []struct {
+		src  string
+		want float64
+	}{
+		{"requests", 200},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"-requests + 1", -199},
+		{"errors / requests", 0.15},
+		{"errors / slow", 0},
+		{"requests > 100", 1},
+		{"requests >= 200 && errors / requests > 0.1", 1},
+		{"requests > 1000 || errors > 10", 1},
+		{"requests > 1000 or slow > 0", 0},
+		{"requests > 100 and not slow > 0", 1},
+		{"!(requests > 100)", 0},
+		{"1 || 0 && 0", 1},
+		{"requests == 200 && errors != 30", 0},
+	}
+	for _, c := range cases {
+		e, err := CompileExpr(c.src, "requests", "errors", "slow")
+		if assert.NoError(t, err, c.src) {
+			assert.InDelta(t, c.want, e.Eval(values), 1e-9, c.src)
+			assert.Equal(t, c.src, e.String())
+		}
+	}
+}
+
+func Test_CompileExpr_errors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"requests >",
+		"(requests > 1",
+		"requests > 1)",
+		"unknown > 1",
+		"requests % 2",
+		"1..2 > 0",
+		"requests requests",
+	} {
+		_, err := CompileExpr(src, "requests")
+		assert.Error(t, err, src)
+	}
+}
+
+func Test_isIdent(t *testing.T) {
+	for _, name := range []string{"errors", "_all", "status_5xx", "Über"} {
+		assert.True(t, isIdent(name), name)
+	}
+	for _, name := range []string{"", "5xx", "api-errors", "a b", "and", "OR", "not"} {
+		assert.False(t, isIdent(name), name)
+	}
+}
@@ -0,0 +1,217 @@
+package alerts
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event is the kind of an alert transition
+type Event string
+
+const (
+	// Fired is recorded when the alert goes over its threshold
+	Fired Event = "fired"
+	// Recovered is recorded when the alert goes back under its threshold
+	Recovered Event = "recovered"
+)
+
+// Transition describes an alert firing or recovering
+type Transition struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Event     Event             `json:"event"`
+	At        time.Time         `json:"at"`
+	Value     int               `json:"value"`
+	Threshold int               `json:"threshold"`
+	// Expr and Values are only set for compound alerts, whose Value is the
+	// count of all logs and Threshold is 0
+	Expr   string             `json:"expr,omitempty"`
+	Values map[string]float64 `json:"values,omitempty"`
+	// Duration is how long the alert was active. Only set when recovering
+	Duration time.Duration `json:"duration,omitempty"`
+	Silenced bool          `json:"silenced,omitempty"`
+}
+
+// Incident is a firing of an alert paired with its recovery. End is zero while
+// the incident is ongoing
+type Incident struct {
+	Name      string
+	Labels    map[string]string
+	Start     time.Time
+	End       time.Time
+	Value     int
+	Threshold int
+	// Expr and Values are only set for compound alerts, with the values the
+	// conditions had when it fired
+	Expr     string
+	Values   map[string]float64
+	Silenced bool
+}
+
+// Duration returns how long the incident lasted, or has lasted so far if ongoing
+func (i Incident) Duration() time.Duration {
+	if i.End.IsZero() {
+		return time.Since(i.Start)
+	}
+	return i.End.Sub(i.Start)
+}
+
+// HistoryFilter selects incidents. Zero fields don't filter
+type HistoryFilter struct {
+	Name        string
+	Since       time.Time
+	Until       time.Time
+	MinDuration time.Duration
+}
+
+func (f HistoryFilter) matches(i Incident) bool {
+	if f.Name != "" && f.Name != i.Name {
+		return false
+	}
+	if !f.Until.IsZero() && i.Start.After(f.Until) {
+		return false
+	}
+	if !f.Since.IsZero() && !i.End.IsZero() && i.End.Before(f.Since) {
+		return false
+	}
+	return i.Duration() >= f.MinDuration
+}
+
+// History is an append-only log of alert transitions kept in a local file, one
+// JSON object per line
+type History struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewHistory constructs a history stored at path
+func NewHistory(path string) *History {
+	h := History{
+		path: path,
+	}
+	return &h
+}
+
+// Record appends a transition to the history
+func (h *History) Record(t Transition) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode transition")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %s", h.path)
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open history %s", h.path)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return errors.Wrapf(err, "failed to append to history %s", h.path)
+	}
+	return nil
+}
+
+// OnTransition can be registered on an alert with Alert.OnTransition. Errors are
+// printed since there is no caller to return them to
+func (h *History) OnTransition(t Transition) {
+	if err := h.Record(t); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+// Transitions reads all recorded transitions in the order they were recorded
+func (h *History) Transitions() ([]Transition, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open history %s", h.path)
+	}
+	defer f.Close()
+
+	transitions := []Transition{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var t Transition
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode history %s line %d", h.path, line)
+		}
+		transitions = append(transitions, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read history %s", h.path)
+	}
+	return transitions, nil
+}
+
+// Incidents pairs up recorded transitions into incidents and returns the ones
+// matching the filter, oldest first
+func (h *History) Incidents(f HistoryFilter) ([]Incident, error) {
+	transitions, err := h.Transitions()
+	if err != nil {
+		return nil, err
+	}
+
+	incidents := []Incident{}
+	// index of the ongoing incident of each alert
+	open := map[string]int{}
+	for _, t := range transitions {
+		switch t.Event {
+		case Fired:
+			open[t.Name] = len(incidents)
+			incidents = append(incidents, Incident{
+				Name:      t.Name,
+				Labels:    t.Labels,
+				Start:     t.At,
+				Value:     t.Value,
+				Threshold: t.Threshold,
+				Expr:      t.Expr,
+				Values:    t.Values,
+				Silenced:  t.Silenced,
+			})
+		case Recovered:
+			i, ok := open[t.Name]
+			if !ok {
+				// fired before the history was kept
+				incidents = append(incidents, Incident{
+					Name:      t.Name,
+					Labels:    t.Labels,
+					Start:     t.At.Add(-t.Duration),
+					End:       t.At,
+					Threshold: t.Threshold,
+					Expr:      t.Expr,
+				})
+				continue
+			}
+			incidents[i].End = t.At
+			delete(open, t.Name)
+		}
+	}
+
+	matched := []Incident{}
+	for _, i := range incidents {
+		if f.matches(i) {
+			matched = append(matched, i)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Start.Before(matched[j].Start)
+	})
+	return matched, nil
+}
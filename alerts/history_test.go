@@ -0,0 +1,84 @@
+package alerts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_History(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	h := NewHistory(filepath.Join(dir, "history.log"))
+
+	incidents, err := h.Incidents(HistoryFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, incidents)
+
+	base := time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, h.Record(Transition{Name: "traffic", Event: Fired, At: base, Value: 10, Threshold: 10}))
+	assert.NoError(t, h.Record(Transition{Name: "errors", Event: Fired, At: base.Add(time.Minute), Value: 5, Expr: "errors > 4", Values: map[string]float64{"errors": 5}}))
+	assert.NoError(t, h.Record(Transition{Name: "traffic", Event: Recovered, At: base.Add(10 * time.Minute), Value: 9, Threshold: 10, Duration: 10 * time.Minute}))
+	assert.NoError(t, h.Record(Transition{Name: "errors", Event: Recovered, At: base.Add(2 * time.Minute), Value: 4, Expr: "errors > 4", Values: map[string]float64{"errors": 4}, Duration: time.Minute}))
+	assert.NoError(t, h.Record(Transition{Name: "traffic", Event: Fired, At: base.Add(time.Hour), Value: 12, Threshold: 10}))
+
+	transitions, err := h.Transitions()
+	assert.NoError(t, err)
+	assert.Len(t, transitions, 5)
+
+	incidents, err = h.Incidents(HistoryFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, incidents, 3)
+	assert.Equal(t, "traffic", incidents[0].Name)
+	assert.Equal(t, 10*time.Minute, incidents[0].Duration())
+	// compound alerts keep the values they fired with
+	assert.Equal(t, "errors > 4", incidents[1].Expr)
+	assert.Equal(t, map[string]float64{"errors": 5}, incidents[1].Values)
+	assert.Equal(t, 12, incidents[2].Value)
+	assert.True(t, incidents[2].End.IsZero())
+
+	incidents, err = h.Incidents(HistoryFilter{Name: "traffic"})
+	assert.NoError(t, err)
+	assert.Len(t, incidents, 2)
+
+	incidents, err = h.Incidents(HistoryFilter{MinDuration: 5 * time.Minute})
+	assert.NoError(t, err)
+	assert.Len(t, incidents, 2)
+
+	incidents, err = h.Incidents(HistoryFilter{Since: base.Add(5 * time.Minute), Until: base.Add(30 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Len(t, incidents, 1)
+	assert.Equal(t, "traffic", incidents[0].Name)
+}
+
+func Test_Alert_OnTransition(t *testing.T) {
+	transitions := []Transition{}
+	a := NewAlert("test", time.Minute, 2)
+	a.OnTransition(func(t Transition) {
+		transitions = append(transitions, t)
+	})
+	a.inc(time.Now())
+	a.checkAndAlert()
+	a.flush()
+	assert.Empty(t, transitions)
+	a.inc(time.Now())
+	a.checkAndAlert()
+	a.flush()
+	assert.Len(t, transitions, 1)
+	assert.Equal(t, Fired, transitions[0].Event)
+	assert.Equal(t, 2, transitions[0].Value)
+	assert.Equal(t, 2, transitions[0].Threshold)
+
+	// move the counts out of the window
+	a.buckets = map[time.Time]int{time.Now().Add(-2 * time.Minute): 2}
+	a.tick()
+	assert.Len(t, transitions, 2)
+	assert.Equal(t, Recovered, transitions[1].Event)
+	assert.Equal(t, 0, transitions[1].Value)
+	assert.True(t, transitions[1].Duration > 0)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package alerts
+
+// lockFile does nothing where flock is not available. Changes saved at the same
+// time by another process may be lost
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,143 @@
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mihaichiorean/monidog/parser"
+)
+
+// Manager runs many alerts off a single log channel. Alerts can be added and
+// removed while the manager is running
+type Manager struct {
+	every time.Duration
+
+	mu     sync.RWMutex
+	alerts map[string]*Alert
+	cancel func()
+}
+
+// NewManager constructs an alert manager that drops expired counts every interval
+func NewManager(every time.Duration) *Manager {
+	m := Manager{
+		every:  every,
+		alerts: map[string]*Alert{},
+	}
+	return &m
+}
+
+// Add registers an alert. Alerts managed here must not be started on their own
+func (m *Manager) Add(a *Alert) error {
+	if a.running() {
+		return fmt.Errorf("%s alert is already started on its own", a.name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.alerts[a.name]; ok {
+		return fmt.Errorf("%s alert already registered", a.name)
+	}
+	m.alerts[a.name] = a
+	return nil
+}
+
+// Remove unregisters the alert with the given name
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.alerts[name]; !ok {
+		return fmt.Errorf("%s alert not registered", name)
+	}
+	delete(m.alerts, name)
+	return nil
+}
+
+// Alert returns the registered alert with the given name
+func (m *Manager) Alert(name string) (*Alert, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.alerts[name]
+	return a, ok
+}
+
+// Alerts returns the registered alerts sorted by name
+func (m *Manager) Alerts() []*Alert {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	alerts := make([]*Alert, 0, len(m.alerts))
+	for _, a := range m.alerts {
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].name < alerts[j].name
+	})
+	return alerts
+}
+
+// Snapshots returns the state of all registered alerts sorted by name
+func (m *Manager) Snapshots() []Snapshot {
+	alerts := m.Alerts()
+	snapshots := make([]Snapshot, 0, len(alerts))
+	for _, a := range alerts {
+		snapshots = append(snapshots, a.Snapshot())
+	}
+	return snapshots
+}
+
+// States returns the current state of all registered alerts sorted by name
+func (m *Manager) States() []State {
+	alerts := m.Alerts()
+	states := make([]State, 0, len(alerts))
+	for _, a := range alerts {
+		states = append(states, a.State())
+	}
+	return states
+}
+
+// Start begins dispatching logs from in to every registered alert
+func (m *Manager) Start(in <-chan parser.Log) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return fmt.Errorf("alert manager already started")
+	}
+	done := make(chan struct{})
+	m.cancel = func() {
+		close(done)
+	}
+	go func() {
+		t := time.NewTicker(m.every)
+		defer t.Stop()
+		for {
+			select {
+			case log, ok := <-in:
+				if !ok {
+					return
+				}
+				for _, a := range m.Alerts() {
+					a.observe(log)
+				}
+			case <-t.C:
+				for _, a := range m.Alerts() {
+					a.tick()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the dispatching of logs
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel == nil {
+		return fmt.Errorf("cannot stop alert manager. not started yet")
+	}
+	m.cancel()
+	m.cancel = nil
+	return nil
+}
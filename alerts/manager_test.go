@@ -0,0 +1,76 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/mihaichiorean/monidog/mocks"
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Manager_register(t *testing.T) {
+	m := NewManager(10 * time.Millisecond)
+	assert.NoError(t, m.Add(NewAlert("b", time.Minute, 1)))
+	assert.NoError(t, m.Add(NewAlert("a", time.Minute, 1)))
+	assert.Error(t, m.Add(NewAlert("a", time.Minute, 5)))
+
+	started := NewAlert("started", time.Minute, 1)
+	assert.NoError(t, started.Start(make(chan parser.Log)))
+	assert.Error(t, m.Add(started))
+	assert.NoError(t, started.Stop())
+
+	snapshots := m.Snapshots()
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "a", snapshots[0].Name)
+	assert.Equal(t, "b", snapshots[1].Name)
+
+	_, ok := m.Alert("a")
+	assert.True(t, ok)
+	assert.NoError(t, m.Remove("a"))
+	assert.Error(t, m.Remove("a"))
+	_, ok = m.Alert("a")
+	assert.False(t, ok)
+}
+
+func Test_Manager_dispatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewManager(10 * time.Millisecond)
+	low := NewAlert("low", time.Minute, 2)
+	high := NewAlert("high", time.Minute, 100)
+	assert.NoError(t, m.Add(low))
+	assert.NoError(t, m.Add(high))
+
+	ch := make(chan parser.Log)
+	assert.NoError(t, m.Start(ch))
+	assert.Error(t, m.Start(ch))
+	for i := 0; i < 3; i++ {
+		l := mocks.NewMockLog(ctrl)
+		l.EXPECT().Timestamp().Return(time.Now()).Times(2)
+		ch <- l
+	}
+
+	// added while running
+	late := NewAlert("late", time.Minute, 1)
+	assert.NoError(t, m.Add(late))
+	l := mocks.NewMockLog(ctrl)
+	l.EXPECT().Timestamp().Return(time.Now()).Times(3)
+	ch <- l
+	// too old to be counted. once it is received the previous log was handled
+	old := mocks.NewMockLog(ctrl)
+	old.EXPECT().Timestamp().Return(time.Now().Add(-time.Hour)).AnyTimes()
+	ch <- old
+	assert.NoError(t, m.Stop())
+	assert.Error(t, m.Stop())
+
+	snapshots := map[string]Snapshot{}
+	for _, s := range m.Snapshots() {
+		snapshots[s.Name] = s
+	}
+	assert.True(t, snapshots["low"].Active)
+	assert.False(t, snapshots["high"].Active)
+	assert.True(t, snapshots["late"].Active)
+}
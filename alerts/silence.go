@@ -0,0 +1,323 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AlertNameLabel is the matcher name that matches against the alert name instead of a label
+const AlertNameLabel = "alertname"
+
+// Silencer decides whether notifications of an alert should be suppressed at a given time
+type Silencer interface {
+	Silenced(name string, labels map[string]string, at time.Time) bool
+}
+
+// Matcher matches the alert name or one of its labels against a value or a regular expression
+type Matcher struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Regex bool   `json:"regex"`
+
+	// re is the compiled Value of regex matchers
+	re *regexp.Regexp
+}
+
+// ParseMatcher parses matchers written as name=value or name=~regex
+func ParseMatcher(s string) (Matcher, error) {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return Matcher{}, fmt.Errorf("invalid matcher %q. expected name=value or name=~regex", s)
+	}
+	m := Matcher{
+		Name:  strings.TrimSpace(s[:i]),
+		Value: s[i+1:],
+	}
+	if strings.HasPrefix(m.Value, "~") {
+		m.Regex = true
+		m.Value = m.Value[1:]
+	}
+	if err := m.compile(); err != nil {
+		return Matcher{}, err
+	}
+	return m, nil
+}
+
+// compile compiles the regex of the matcher, anchored to the whole value
+func (m *Matcher) compile() error {
+	if !m.Regex {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + m.Value + ")$")
+	if err != nil {
+		return errors.Wrapf(err, "invalid matcher regex %q", m.Value)
+	}
+	m.re = re
+	return nil
+}
+
+// Matches checks the matcher against an alert
+func (m Matcher) Matches(name string, labels map[string]string) bool {
+	v := labels[m.Name]
+	if m.Name == AlertNameLabel {
+		v = name
+	}
+	if !m.Regex {
+		return v == m.Value
+	}
+	if m.re == nil {
+		// not parsed nor loaded, e.g. a literal
+		if err := m.compile(); err != nil {
+			return false
+		}
+	}
+	return m.re.MatchString(v)
+}
+
+func (m Matcher) String() string {
+	if m.Regex {
+		return m.Name + "=~" + m.Value
+	}
+	return m.Name + "=" + m.Value
+}
+
+// Silence suppresses notifications of the alerts matched by all of its matchers
+// between StartsAt and EndsAt
+type Silence struct {
+	ID       string    `json:"id"`
+	Matchers []Matcher `json:"matchers"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+	Comment  string    `json:"comment"`
+}
+
+// compile compiles the regexes of the matchers once, rather than on every match
+func (s *Silence) compile() error {
+	for i := range s.Matchers {
+		if err := s.Matchers[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Active tells if the silence is in effect at the given time
+func (s Silence) Active(at time.Time) bool {
+	return !at.Before(s.StartsAt) && at.Before(s.EndsAt)
+}
+
+// Expired tells if the silence has ended by the given time
+func (s Silence) Expired(at time.Time) bool {
+	return !at.Before(s.EndsAt)
+}
+
+// Matches tells if all the matchers of this silence match the alert
+func (s Silence) Matches(name string, labels map[string]string) bool {
+	if len(s.Matchers) == 0 {
+		return false
+	}
+	for _, m := range s.Matchers {
+		if !m.Matches(name, labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Silences is a set of silences persisted to a local file. Changes made to the file
+// by another process (e.g. the silence subcommand) are picked up on the next check.
+// Changes are saved with the file locked and read again, so that processes
+// adding or expiring silences at the same time don't lose each other's changes
+type Silences struct {
+	path string
+
+	mu       sync.Mutex
+	silences []Silence
+	modTime  time.Time
+}
+
+// OpenSilences loads the silences stored at path. A missing file is an empty set
+func OpenSilences(path string) (*Silences, error) {
+	s := Silences{
+		path: path,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// load reads the file if it changed since the last read
+func (s *Silences) load() error {
+	fi, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.silences = nil
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat silences file %s", s.path)
+	}
+	if fi.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read silences from %s", s.path)
+	}
+	silences := []Silence{}
+	if err := json.Unmarshal(b, &silences); err != nil {
+		return errors.Wrapf(err, "failed to decode silences from %s", s.path)
+	}
+	for i := range silences {
+		if err := silences[i].compile(); err != nil {
+			return errors.Wrapf(err, "invalid silence %s in %s", silences[i].ID, s.path)
+		}
+	}
+	s.silences = silences
+	s.modTime = fi.ModTime()
+	return nil
+}
+
+// save drops expired silences and writes the rest to the file
+func (s *Silences) save() error {
+	now := time.Now()
+	silences := []Silence{}
+	for _, sil := range s.silences {
+		if !sil.Expired(now) {
+			silences = append(silences, sil)
+		}
+	}
+	b, err := json.MarshalIndent(silences, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode silences")
+	}
+	// written aside and renamed over the file, so that readers never see it
+	// half written
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary file for %s", s.path)
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrapf(err, "failed to write silences to %s", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrapf(err, "failed to replace silences file %s", s.path)
+	}
+	s.silences = silences
+	if fi, err := os.Stat(s.path); err == nil {
+		s.modTime = fi.ModTime()
+	}
+	return nil
+}
+
+// Add stores a new silence and returns it with its generated ID
+func (s *Silences) Add(sil Silence) (Silence, error) {
+	if len(sil.Matchers) == 0 {
+		return sil, fmt.Errorf("silence needs at least one matcher")
+	}
+	if !sil.EndsAt.After(sil.StartsAt) {
+		return sil, fmt.Errorf("silence must end after it starts")
+	}
+	if err := sil.compile(); err != nil {
+		return sil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return sil, errors.Wrap(err, "failed to generate silence id")
+	}
+	sil.ID = hex.EncodeToString(id)
+
+	return sil, s.update(func() error {
+		s.silences = append(s.silences, sil)
+		return nil
+	})
+}
+
+// Expire ends the silence with the given id and removes it
+func (s *Silences) Expire(id string) error {
+	return s.update(func() error {
+		for i, sil := range s.silences {
+			if sil.ID == id {
+				s.silences = append(s.silences[:i], s.silences[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("silence %s not found", id)
+	})
+}
+
+// update changes the silences and saves them while holding the lock file, after
+// reading them again in case another process saved in the meantime
+func (s *Silences) update(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// the file may have changed within the resolution of its mod time
+	s.modTime = time.Time{}
+	if err := s.load(); err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// List returns the silences that have not expired yet, including future ones
+func (s *Silences) List() ([]Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := []Silence{}
+	for _, sil := range s.silences {
+		if !sil.Expired(now) {
+			list = append(list, sil)
+		}
+	}
+	return list, nil
+}
+
+// Silenced implements Silencer. It only reads the file; expired silences are
+// skipped here and dropped from it by the next Add or Expire
+func (s *Silences) Silenced(name string, labels map[string]string, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+	for _, sil := range s.silences {
+		if sil.Active(at) && sil.Matches(name, labels) {
+			return true
+		}
+	}
+	return false
+}
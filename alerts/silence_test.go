@@ -0,0 +1,250 @@
+package alerts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/mihaichiorean/monidog/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseMatcher(t *testing.T) {
+	m, err := ParseMatcher("alertname=high traffic")
+	assert.NoError(t, err)
+	assert.Equal(t, Matcher{Name: AlertNameLabel, Value: "high traffic"}, m)
+	assert.True(t, m.Matches("high traffic", nil))
+	assert.False(t, m.Matches("low traffic", nil))
+
+	m, err = ParseMatcher("env=~prod|staging")
+	assert.NoError(t, err)
+	assert.True(t, m.Regex)
+	assert.True(t, m.Matches("x", map[string]string{"env": "staging"}))
+	assert.False(t, m.Matches("x", map[string]string{"env": "production"}))
+	assert.Equal(t, "env=~prod|staging", m.String())
+	assert.NotNil(t, m.re)
+
+	_, err = ParseMatcher("=value")
+	assert.Error(t, err)
+	_, err = ParseMatcher("env=~(")
+	assert.Error(t, err)
+}
+
+func Test_Silences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silences")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "silences.json")
+
+	s, err := OpenSilences(path)
+	assert.NoError(t, err)
+	now := time.Now()
+	labels := map[string]string{"env": "prod"}
+	assert.False(t, s.Silenced("high traffic", labels, now))
+
+	_, err = s.Add(Silence{StartsAt: now, EndsAt: now.Add(time.Hour)})
+	assert.Error(t, err)
+
+	sil, err := s.Add(Silence{
+		Matchers: []Matcher{{Name: AlertNameLabel, Value: "high traffic"}, {Name: "env", Value: "prod"}},
+		StartsAt: now.Add(-time.Minute),
+		EndsAt:   now.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, sil.ID)
+	assert.True(t, s.Silenced("high traffic", labels, now))
+	assert.False(t, s.Silenced("high traffic", map[string]string{"env": "dev"}, now))
+	assert.False(t, s.Silenced("high traffic", labels, now.Add(2*time.Hour)))
+
+	// another process sees the silence
+	other, err := OpenSilences(path)
+	assert.NoError(t, err)
+	list, err := other.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	// regexes are compiled when the file is loaded
+	_, err = other.Add(Silence{
+		Matchers: []Matcher{{Name: "env", Value: "st.*", Regex: true}},
+		StartsAt: now.Add(-time.Minute),
+		EndsAt:   now.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	list, err = s.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.NotNil(t, list[1].Matchers[0].re)
+	assert.True(t, s.Silenced("x", map[string]string{"env": "staging"}, now))
+	assert.False(t, s.Silenced("x", map[string]string{"env": "dev"}, now))
+
+	assert.NoError(t, other.Expire(sil.ID))
+	assert.Error(t, other.Expire(sil.ID))
+	assert.False(t, s.Silenced("high traffic", labels, now))
+}
+
+func Test_Silences_concurrentSaves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silences")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "silences.json")
+
+	// like the silence subcommand and a running monitor, each with its own view
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		s, err := OpenSilences(path)
+		assert.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := s.Add(Silence{
+					Matchers: []Matcher{{Name: AlertNameLabel, Value: "test"}},
+					StartsAt: now,
+					EndsAt:   now.Add(time.Hour),
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s, err := OpenSilences(path)
+	assert.NoError(t, err)
+	list, err := s.List()
+	assert.NoError(t, err)
+	// no silence was lost
+	assert.Len(t, list, 80)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	// the silences and their lock file
+	assert.Len(t, files, 2)
+}
+
+func Test_Silences_invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silences")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "silences.json")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`[{"id":"1","matchers":[{"name":"env","value":"(","regex":true}]}]`), 0644))
+	_, err = OpenSilences(path)
+	assert.Error(t, err)
+
+	s, err := OpenSilences(filepath.Join(dir, "other.json"))
+	assert.NoError(t, err)
+	now := time.Now()
+	_, err = s.Add(Silence{
+		Matchers: []Matcher{{Name: "env", Value: "(", Regex: true}},
+		StartsAt: now,
+		EndsAt:   now.Add(time.Hour),
+	})
+	assert.Error(t, err)
+}
+
+func Test_Silences_cleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silences")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "silences.json")
+
+	s, err := OpenSilences(path)
+	assert.NoError(t, err)
+	now := time.Now()
+	_, err = s.Add(Silence{
+		Matchers: []Matcher{{Name: AlertNameLabel, Value: "test"}},
+		StartsAt: now,
+		EndsAt:   now.Add(20 * time.Millisecond),
+	})
+	assert.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, s.Silenced("test", nil, time.Now()))
+	// checking doesn't write, the next change drops it
+	list := []Silence{}
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &list))
+	assert.Len(t, list, 1)
+
+	other, err := s.Add(Silence{
+		Matchers: []Matcher{{Name: AlertNameLabel, Value: "other"}},
+		StartsAt: now,
+		EndsAt:   now.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	b, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &list))
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, other.ID, list[0].ID)
+	}
+}
+
+func Test_Alert_silenced(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silences")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := OpenSilences(filepath.Join(dir, "silences.json"))
+	assert.NoError(t, err)
+	now := time.Now()
+	_, err = s.Add(Silence{
+		Matchers: []Matcher{{Name: "team", Value: "web"}},
+		StartsAt: now.Add(-time.Minute),
+		EndsAt:   now.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	a := NewAlert("test", time.Minute, 1)
+	a.SetLabels(map[string]string{"team": "web"})
+	a.SetSilencer(s)
+	var transitions []Transition
+	a.OnTransition(func(tr Transition) { transitions = append(transitions, tr) })
+	a.inc(now)
+	a.checkAndAlert()
+	a.flush()
+	// state is still tracked, nothing was notified
+	assert.True(t, a.active)
+	assert.True(t, a.notified.IsZero())
+	assert.Len(t, transitions, 1)
+	assert.True(t, transitions[0].Silenced)
+}
+
+// stateSilencer queries the state of the alert it silences, which blocks if the
+// alert holds its lock while checking silences
+type stateSilencer struct {
+	a        *Alert
+	unlocked bool
+}
+
+func (s *stateSilencer) Silenced(name string, labels map[string]string, at time.Time) bool {
+	done := make(chan struct{})
+	go func() {
+		s.a.State()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.unlocked = true
+	case <-time.After(time.Second):
+	}
+	return false
+}
+
+func Test_Alert_silencedUnlocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	l := mocks.NewMockLog(ctrl)
+	l.EXPECT().Timestamp().Return(time.Now())
+
+	a := NewAlert("test", time.Minute, 1)
+	s := &stateSilencer{a: a}
+	a.SetSilencer(s)
+	a.observe(l)
+	assert.True(t, s.unlocked)
+	assert.False(t, a.notified.IsZero())
+}
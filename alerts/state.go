@@ -0,0 +1,224 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrStaleSnapshot is returned when restoring a snapshot that no longer overlaps the alert window
+var ErrStaleSnapshot = errors.New("snapshot is older than the alert window")
+
+// BucketCount is the number of hits recorded in the bucket starting at Ts
+type BucketCount struct {
+	Ts    time.Time `json:"ts"`
+	Count int       `json:"count"`
+}
+
+// Snapshot is a serializable copy of an alert's state, used to carry an ongoing
+// incident across restarts
+type Snapshot struct {
+	Name     string        `json:"name"`
+	Taken    time.Time     `json:"taken"`
+	Active   bool          `json:"active"`
+	Since    time.Time     `json:"since"`
+	Notified time.Time     `json:"notified"`
+	Buckets  []BucketCount `json:"buckets"`
+	// Conditions holds the counts of each condition of compound alerts
+	Conditions map[string][]BucketCount `json:"conditions,omitempty"`
+}
+
+// Snapshot returns a copy of the current alert state
+func (a *Alert) Snapshot() Snapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := Snapshot{
+		Name:     a.name,
+		Taken:    time.Now(),
+		Active:   a.active,
+		Since:    a.since,
+		Notified: a.notified,
+		Buckets:  a.counts(),
+	}
+	if len(a.conds) > 0 {
+		s.Conditions = map[string][]BucketCount{}
+		for _, c := range a.conds {
+			s.Conditions[c.Name] = c.counts()
+		}
+	}
+	return s
+}
+
+// Restore loads a snapshot into the alert. Counts that fell out of the window while
+// the process was down are dropped; the active flag is kept so an ongoing incident
+// does not page again, and a resolved one is reported as recovered on the next check
+func (a *Alert) Restore(s Snapshot) error {
+	if s.Name != a.name {
+		return fmt.Errorf("cannot restore %s snapshot into %s alert", s.Name, a.name)
+	}
+	now := time.Now()
+	if s.Taken.Before(now.Add(-(a.window))) {
+		return ErrStaleSnapshot
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.restore(s.Buckets, now)
+	for _, c := range a.conds {
+		c.restore(s.Conditions[c.Name], now)
+	}
+	a.active = s.Active
+	a.since = s.Since
+	a.notified = s.Notified
+	return nil
+}
+
+type stateFile struct {
+	Saved  time.Time  `json:"saved"`
+	Alerts []Snapshot `json:"alerts"`
+}
+
+// SaveState writes alert snapshots to path. The file is replaced atomically so a
+// crash mid-write leaves the previous state intact
+func SaveState(path string, snapshots []Snapshot) error {
+	b, err := json.Marshal(stateFile{
+		Saved:  time.Now(),
+		Alerts: snapshots,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode alert state")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create state directory for %s", path)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write alert state to %s", tmp)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrapf(err, "failed to replace alert state %s", path)
+	}
+	return nil
+}
+
+// LoadState reads alert snapshots from path. A missing file is not an error
+func LoadState(path string) ([]Snapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read alert state from %s", path)
+	}
+	var f stateFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode alert state from %s", path)
+	}
+	return f.Alerts, nil
+}
+
+// Persister periodically saves the state of a set of alerts to a file
+type Persister struct {
+	path   string
+	every  time.Duration
+	alerts []*Alert
+
+	mu     sync.Mutex
+	cancel func()
+	done   chan struct{}
+}
+
+// NewPersister constructs a persister that saves the given alerts to path every interval
+func NewPersister(path string, every time.Duration, alerts ...*Alert) *Persister {
+	p := Persister{
+		path:   path,
+		every:  every,
+		alerts: alerts,
+	}
+	return &p
+}
+
+// Restore loads the state file and restores every alert that has a fresh snapshot.
+// Alerts without a snapshot, or with a stale one, start from scratch
+func (p *Persister) Restore() error {
+	snapshots, err := LoadState(p.path)
+	if err != nil {
+		return err
+	}
+	byName := map[string]Snapshot{}
+	for _, s := range snapshots {
+		byName[s.Name] = s
+	}
+	for _, a := range p.alerts {
+		s, ok := byName[a.name]
+		if !ok {
+			continue
+		}
+		if err := a.Restore(s); err != nil && err != ErrStaleSnapshot {
+			return errors.Wrapf(err, "failed to restore %s alert", a.name)
+		}
+	}
+	return nil
+}
+
+// Save writes the current state of all alerts
+func (p *Persister) Save() error {
+	snapshots := make([]Snapshot, 0, len(p.alerts))
+	for _, a := range p.alerts {
+		snapshots = append(snapshots, a.Snapshot())
+	}
+	return SaveState(p.path, snapshots)
+}
+
+// Start begins saving alert state periodically
+func (p *Persister) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return fmt.Errorf("persister for %s already started", p.path)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	p.cancel = func() {
+		close(stop)
+	}
+	p.done = done
+	go func() {
+		defer close(done)
+		t := time.NewTicker(p.every)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := p.Save(); err != nil {
+					fmt.Fprintln(os.Stderr, err.Error())
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the periodic saving and writes a final snapshot
+func (p *Persister) Stop() error {
+	p.mu.Lock()
+	if p.cancel == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("cannot stop persister for %s. not started yet", p.path)
+	}
+	p.cancel()
+	p.cancel = nil
+	done := p.done
+	p.mu.Unlock()
+
+	<-done
+	return p.Save()
+}
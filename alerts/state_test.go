@@ -0,0 +1,96 @@
+package alerts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SnapshotRestore(t *testing.T) {
+	a := NewAlert("test", 1*time.Minute, 3)
+	now := time.Now()
+	a.inc(now.Add(-10 * time.Second))
+	a.inc(now.Add(-5 * time.Second))
+	a.inc(now)
+	a.checkAndAlert()
+	assert.True(t, a.active)
+
+	dir, err := ioutil.TempDir("", "alerts")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	assert.NoError(t, SaveState(path, []Snapshot{a.Snapshot()}))
+	snapshots, err := LoadState(path)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+
+	b := NewAlert("test", 1*time.Minute, 3)
+	assert.NoError(t, b.Restore(snapshots[0]))
+	assert.Equal(t, 3, b.total)
+	assert.True(t, b.active)
+	assert.Equal(t, a.since.Unix(), b.since.Unix())
+
+	// already active, no new transition
+	b.checkAndAlert()
+	assert.Equal(t, a.notified.Unix(), b.notified.Unix())
+}
+
+func Test_Restore_stale(t *testing.T) {
+	a := NewAlert("test", 1*time.Minute, 3)
+	s := Snapshot{
+		Name:    "test",
+		Taken:   time.Now().Add(-2 * time.Minute),
+		Active:  true,
+		Buckets: []BucketCount{{Ts: time.Now().Add(-2 * time.Minute), Count: 5}},
+	}
+	assert.Equal(t, ErrStaleSnapshot, a.Restore(s))
+	assert.False(t, a.active)
+	assert.Equal(t, 0, a.total)
+
+	s.Name = "other"
+	assert.Error(t, a.Restore(s))
+}
+
+func Test_Restore_dropsOldBuckets(t *testing.T) {
+	a := NewAlert("test", 1*time.Minute, 3)
+	now := time.Now()
+	s := Snapshot{
+		Name:   "test",
+		Taken:  now.Add(-30 * time.Second),
+		Active: true,
+		Buckets: []BucketCount{
+			{Ts: now.Add(-90 * time.Second), Count: 5},
+			{Ts: now.Add(-20 * time.Second), Count: 1},
+		},
+	}
+	assert.NoError(t, a.Restore(s))
+	assert.Equal(t, 1, a.total)
+	// the incident ended while we were down
+	a.clear()
+	assert.False(t, a.active)
+}
+
+func Test_Persister(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alerts")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	a := NewAlert("test", 1*time.Minute, 10)
+	a.inc(time.Now())
+	p := NewPersister(path, 1*time.Millisecond, a)
+	assert.NoError(t, p.Restore())
+	assert.NoError(t, p.Start())
+	assert.Error(t, p.Start())
+	assert.NoError(t, p.Stop())
+	assert.Error(t, p.Stop())
+
+	b := NewAlert("test", 1*time.Minute, 10)
+	assert.NoError(t, NewPersister(path, time.Second, b).Restore())
+	assert.Equal(t, 1, b.total)
+}
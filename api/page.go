@@ -0,0 +1,142 @@
+package api
+
+// page is the web UI served at /. It polls the API every 2 seconds and needs
+// nothing but a browser
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>monidog</title>
+<style>
+body { font-family: monospace; margin: 1em 2em; background: #fafafa; color: #222; }
+h2 { margin: 1.2em 0 .4em; font-size: 1.1em; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 12px 2px 0; text-align: left; }
+td.n { text-align: right; }
+.active { color: #c00; font-weight: bold; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+<h1>monidog</h1>
+<div>window <select id="window"></select> <span id="range" class="muted"></span></div>
+<h2>sections</h2>
+<table id="sections"></table>
+<div id="total"></div>
+<h2>alerts</h2>
+<table id="alerts"></table>
+<h2>history</h2>
+<table id="history"></table>
+<h2>inputs</h2>
+<table id="inputs"></table>
+<p id="error" class="active"></p>
+<script>
+var selected = "current";
+
+function el(tag, text, cls) {
+  var e = document.createElement(tag);
+  e.textContent = text;
+  if (cls) { e.className = cls; }
+  return e;
+}
+
+function fill(id, header, rows) {
+  var t = document.getElementById(id);
+  t.innerHTML = "";
+  var tr = document.createElement("tr");
+  header.forEach(function (h) { tr.appendChild(el("th", h)); });
+  t.appendChild(tr);
+  rows.forEach(function (r) {
+    var tr = document.createElement("tr");
+    r.cells.forEach(function (c) {
+      tr.appendChild(el("td", c, typeof c === "number" ? "n" : ""));
+    });
+    if (r.cls) { tr.className = r.cls; }
+    t.appendChild(tr);
+  });
+}
+
+function duration(ns) {
+  var s = ns / 1e9;
+  if (s >= 3600 && s % 3600 === 0) { return (s / 3600) + "h"; }
+  if (s >= 60 && s % 60 === 0) { return (s / 60) + "m"; }
+  return s + "s";
+}
+
+function time(t) {
+  return t && t.indexOf("0001-") !== 0 ? new Date(t).toLocaleTimeString() : "";
+}
+
+function errors(s) {
+  var e = (s.statuses["4xx"] || 0) + (s.statuses["5xx"] || 0);
+  return s.hits ? (100 * e / s.hits).toFixed(1) + "%" : "0.0%";
+}
+
+function showReport(resp) {
+  var sel = document.getElementById("window");
+  var options = [["current", "current"]];
+  resp.windows.forEach(function (w) { options.push([String(w.window), duration(w.window)]); });
+  sel.innerHTML = "";
+  options.forEach(function (o) {
+    var opt = el("option", o[1]);
+    opt.value = o[0];
+    opt.selected = o[0] === selected;
+    sel.appendChild(opt);
+  });
+  var rep = resp.current;
+  resp.windows.forEach(function (w) { if (String(w.window) === selected) { rep = w; } });
+  document.getElementById("range").textContent = time(rep.start) + " - " + (time(rep.end) || "now");
+  fill("sections", ["section", "hits", "errors", "bytes", "clients"], (rep.sections || []).map(function (s) {
+    return { cells: [s.name, s.hits, errors(s.stats), s.stats.bytes, s.unique_clients] };
+  }));
+  document.getElementById("total").textContent = "total " + rep.total.hits + " hits, " +
+    errors(rep.total) + " errors, " + rep.unique_clients + " clients";
+}
+
+function showAlerts(resp) {
+  fill("alerts", ["alert", "state", "count", "threshold", "since"], resp.alerts.map(function (a) {
+    return {
+      cells: [a.name, a.active ? "ACTIVE" : "ok", a.count, a.threshold, a.active ? time(a.since) : ""],
+      cls: a.active ? "active" : ""
+    };
+  }));
+  fill("history", ["at", "alert", "event", "value"], resp.history.slice().reverse().map(function (t) {
+    return { cells: [time(t.at), t.name, t.event + (t.silenced ? " (silenced)" : ""), t.value] };
+  }));
+}
+
+function showInputs(resp) {
+  fill("inputs", ["path", "offset", "size", "lag", "lines", "parse errors"], resp.map(function (i) {
+    return { cells: [i.path, i.offset, i.size, i.lag, i.lines, i.parse_errors] };
+  }));
+}
+
+function get(path, show) {
+  return fetch(path).then(function (r) {
+    if (!r.ok) { throw new Error(path + ": " + r.status); }
+    return r.json();
+  }).then(show);
+}
+
+function refresh() {
+  Promise.all([
+    get("api/report", showReport),
+    get("api/alerts", showAlerts),
+    get("api/inputs", showInputs)
+  ]).then(function () {
+    document.getElementById("error").textContent = "";
+  }, function (err) {
+    document.getElementById("error").textContent = err.message;
+  });
+}
+
+document.getElementById("window").onchange = function (e) {
+  selected = e.target.value;
+  refresh();
+};
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
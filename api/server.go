@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/monitor"
+	"github.com/mihaichiorean/monidog/reporter"
+)
+
+// how many of the most recent alert transitions /api/alerts returns
+const maxTransitions = 100
+
+// ReportResponse is the body of /api/report
+type ReportResponse struct {
+	// Current is the last report window up to now, not reported yet
+	Current reporter.Report `json:"current"`
+	// Windows is the last report of every window, finest first
+	Windows []reporter.Report `json:"windows"`
+}
+
+// AlertsResponse is the body of /api/alerts
+type AlertsResponse struct {
+	Alerts []alerts.State `json:"alerts"`
+	// History is the most recent transitions, oldest first
+	History []alerts.Transition `json:"history"`
+}
+
+// Input is the progress of a scanner through a file, in /api/inputs
+type Input struct {
+	Path        string `json:"path"`
+	Offset      int64  `json:"offset"`
+	Size        int64  `json:"size"`
+	Lag         int64  `json:"lag"`
+	Lines       int    `json:"lines"`
+	ParseErrors int    `json:"parse_errors"`
+}
+
+type input struct {
+	path    string
+	scanner monitor.ProgressReporter
+}
+
+// Server serves the state of a reporter, alerts and inputs as JSON under /api/
+// and a web page showing it at /. It is an http.Handler
+type Server struct {
+	reporter *reporter.Reporter
+	states   func() []alerts.State
+	mux      *http.ServeMux
+
+	mu sync.Mutex
+	// the most recent alert transitions, oldest first
+	transitions []alerts.Transition
+	inputs      []input
+}
+
+// New constructs a server for a reporter. /api/alerts lists what states
+// returns at the time of the request
+func New(r *reporter.Reporter, states func() []alerts.State) *Server {
+	s := Server{
+		reporter: r,
+		states:   states,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/", s.servePage)
+	s.mux.HandleFunc("/api/report", s.serveReport)
+	s.mux.HandleFunc("/api/alerts", s.serveAlerts)
+	s.mux.HandleFunc("/api/inputs", s.serveInputs)
+	return &s
+}
+
+// SetHistory reads the recent alert transitions from a history once. Later ones
+// are added with OnTransition, so the file isn't read again on every request
+func (s *Server) SetHistory(h *alerts.History) error {
+	transitions, err := h.Transitions()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transitions = append(transitions, s.transitions...)
+	s.trim()
+	return nil
+}
+
+// OnTransition records an alert transition for /api/alerts. Register it with
+// Alert.OnTransition
+func (s *Server) OnTransition(t alerts.Transition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transitions = append(s.transitions, t)
+	s.trim()
+}
+
+// trim keeps the most recent transitions only
+func (s *Server) trim() {
+	if len(s.transitions) > maxTransitions {
+		s.transitions = append([]alerts.Transition(nil), s.transitions[len(s.transitions)-maxTransitions:]...)
+	}
+}
+
+// AddInput adds a file whose progress is served by /api/inputs
+func (s *Server) AddInput(path string, p monitor.ProgressReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inputs = append(s.inputs, input{path: path, scanner: p})
+}
+
+// Handle serves another handler from the same server, e.g. metrics
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// ServeHTTP serves a request
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(w, req)
+}
+
+func (s *Server) servePage(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
+
+func (s *Server) serveReport(w http.ResponseWriter, req *http.Request) {
+	resp := ReportResponse{
+		Current: s.reporter.Snapshot(),
+		Windows: s.reporter.Latest(),
+	}
+	if resp.Windows == nil {
+		resp.Windows = []reporter.Report{}
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) serveAlerts(w http.ResponseWriter, req *http.Request) {
+	resp := AlertsResponse{Alerts: []alerts.State{}, History: []alerts.Transition{}}
+	if s.states != nil {
+		resp.Alerts = s.states()
+	}
+	s.mu.Lock()
+	resp.History = append(resp.History, s.transitions...)
+	s.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+func (s *Server) serveInputs(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	inputs := append([]input(nil), s.inputs...)
+	s.mu.Unlock()
+	resp := make([]Input, 0, len(inputs))
+	for _, in := range inputs {
+		st := in.scanner.Stats()
+		resp = append(resp, Input{
+			Path:        in.path,
+			Offset:      st.Offset,
+			Size:        st.Size,
+			Lag:         st.Lag(),
+			Lines:       st.Lines,
+			ParseErrors: st.ParseErrors,
+		})
+	}
+	writeJSON(w, resp)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(b, '\n'))
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/monitor"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/stretchr/testify/assert"
+)
+
+type scanner monitor.Stats
+
+func (s scanner) Stats() monitor.Stats {
+	return monitor.Stats(s)
+}
+
+func get(t *testing.T, h http.Handler, path string, v interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	if v != nil {
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+	return w
+}
+
+func Test_Server(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monidog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r := reporter.NewReporter(10 * time.Second)
+	states := []alerts.State{{Name: "high traffic", Active: true, Count: 12, Threshold: 10}}
+	s := New(r, func() []alerts.State { return states })
+
+	var rep ReportResponse
+	get(t, s, "/api/report", &rep)
+	assert.Equal(t, []reporter.Report{}, rep.Windows)
+	assert.Equal(t, 0, rep.Current.Total.Hits)
+
+	var al AlertsResponse
+	get(t, s, "/api/alerts", &al)
+	assert.Equal(t, "high traffic", al.Alerts[0].Name)
+	assert.True(t, al.Alerts[0].Active)
+	assert.Empty(t, al.History)
+
+	h := alerts.NewHistory(filepath.Join(dir, "history.log"))
+	at := time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < maxTransitions+1; i++ {
+		assert.NoError(t, h.Record(alerts.Transition{Name: "high traffic", Event: alerts.Fired, At: at, Value: i}))
+	}
+	assert.NoError(t, s.SetHistory(h))
+	get(t, s, "/api/alerts", &al)
+	assert.Len(t, al.History, maxTransitions)
+	assert.Equal(t, maxTransitions, al.History[maxTransitions-1].Value)
+
+	// later transitions come from the alerts, the history isn't read again
+	assert.NoError(t, os.Remove(filepath.Join(dir, "history.log")))
+	s.OnTransition(alerts.Transition{Name: "high traffic", Event: alerts.Recovered, At: at.Add(time.Minute)})
+	get(t, s, "/api/alerts", &al)
+	assert.Len(t, al.History, maxTransitions)
+	assert.Equal(t, 2, al.History[0].Value)
+	assert.Equal(t, alerts.Recovered, al.History[maxTransitions-1].Event)
+
+	var inputs []Input
+	get(t, s, "/api/inputs", &inputs)
+	assert.Empty(t, inputs)
+	s.AddInput("/var/log/access.log", scanner{Offset: 10, Size: 25, Lines: 3, ParseErrors: 1})
+	get(t, s, "/api/inputs", &inputs)
+	assert.Equal(t, []Input{{Path: "/var/log/access.log", Offset: 10, Size: 25, Lag: 15, Lines: 3, ParseErrors: 1}}, inputs)
+
+	w := get(t, s, "/", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "api/report")
+	assert.Equal(t, http.StatusNotFound, get(t, s, "/nope", nil).Code)
+
+	s.Handle("/metrics", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("metrics"))
+	}))
+	assert.Equal(t, "metrics", get(t, s, "/metrics", nil).Body.String())
+}
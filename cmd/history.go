@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyName        string
+	historySince       string
+	historyUntil       string
+	historyMinDuration time.Duration
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past alert incidents",
+	Example: `  monidog history --name "high traffic" --since 24h
+  monidog history --since 2018-11-10T00:00:00Z --until 2018-11-11T00:00:00Z --min-duration 5m`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := alerts.HistoryFilter{
+			Name:        historyName,
+			MinDuration: historyMinDuration,
+		}
+		var err error
+		if f.Since, err = parseTime(historySince); err != nil {
+			return errors.Wrap(err, "invalid --since")
+		}
+		if f.Until, err = parseTime(historyUntil); err != nil {
+			return errors.Wrap(err, "invalid --until")
+		}
+
+		incidents, err := alerts.NewHistory(historyPath()).Incidents(f)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSTART\tEND\tDURATION\tVALUE\tTHRESHOLD")
+		for _, i := range incidents {
+			end := "ongoing"
+			if !i.End.IsZero() {
+				end = i.End.Format(time.RFC3339)
+			}
+			name := i.Name
+			if i.Silenced {
+				name += " (silenced)"
+			}
+			value, threshold := strconv.Itoa(i.Value), strconv.Itoa(i.Threshold)
+			if i.Expr != "" {
+				// compound alerts fire on their expression over the conditions
+				value, threshold = alerts.FormatValues(i.Values), i.Expr
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				name,
+				i.Start.Format(time.RFC3339),
+				end,
+				i.Duration().Round(time.Second),
+				value,
+				threshold,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyName, "name", "n", "", "only show incidents of this alert")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only show incidents active after this time. RFC3339 or a duration ago, e.g. 24h")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "only show incidents started before this time. RFC3339 or a duration ago")
+	historyCmd.Flags().DurationVar(&historyMinDuration, "min-duration", 0, "only show incidents that lasted at least this long")
+	rootCmd.AddCommand(historyCmd)
+}
+
+func historyPath() string {
+	return filepath.Join(dataDir, "history.log")
+}
+
+// parseTime accepts RFC3339 timestamps or a duration meaning that long ago
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
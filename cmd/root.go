@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/api"
+	"github.com/mihaichiorean/monidog/dashboard"
+	"github.com/mihaichiorean/monidog/format"
+	"github.com/mihaichiorean/monidog/metrics"
+	"github.com/mihaichiorean/monidog/monitor"
+	"github.com/mihaichiorean/monidog/output"
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	logFile       string
+	logFormat     string
+	dataDir       string
+	alertsFile    string
+	lateness      time.Duration
+	topK          int
+	horizon       time.Duration
+	windows       string
+	dash          bool
+	metricsAddr   string
+	httpAddr      string
+	outputName    string
+	sinks         []string
+	flushInterval time.Duration
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "monidog",
+	Short: "monidog tails an access log, prints traffic stats and alerts on high traffic",
+	RunE:  run,
+}
+
+func init() {
+	rootCmd.Flags().StringVar(&logFile, "log", "/var/log/access.log", "access log file to monitor, PATH[,section=SPEC] where SPEC is how sections are derived from its request paths: last-slash (default), segments:N, regex:EXPR, template or host:SPEC")
+	rootCmd.Flags().StringVar(&logFormat, "log-format", "combined", "format of the log: combined (common, combined or LTSV), nginx:LOG_FORMAT, apache:LOG_FORMAT or json:FIELD=PATH,...,layout=LAYOUT, e.g. 'nginx:$remote_addr [$time_local] \"$request\" $status $request_time'")
+	rootCmd.Flags().StringVar(&windows, "windows", "10s", "comma separated report windows, each a multiple of the previous, e.g. 10s,1m,5m,1h")
+	rootCmd.Flags().IntVar(&topK, "top", 5, "how many of the top clients, user agents, referrers and paths to report per window. 0 turns them off")
+	rootCmd.Flags().DurationVar(&horizon, "unique-horizon", 2*time.Minute, "longer period distinct clients and urls are estimated over, besides the report window")
+	rootCmd.Flags().StringVar(&alertsFile, "alerts", "", "JSON file declaring the alert rules (default: alert on 10 requests in 2 minutes)")
+	rootCmd.Flags().DurationVar(&lateness, "allowed-lateness", 0, "how late logs may arrive and still be counted. Delays reports by as much")
+	rootCmd.Flags().StringVar(&outputName, "output", "text", "format of reports and alert transitions: text, json, csv or logfmt")
+	rootCmd.Flags().StringArrayVar(&sinks, "sink", nil, "also ship parsed logs and reports to a sink, e.g. file:/var/log/monidog.jsonl, syslog+udp://host:514, tcp://host:port, https://host/path or dogstatsd://localhost:8125. Repeatable")
+	rootCmd.Flags().DurationVar(&flushInterval, "sink-flush-interval", output.DefaultFlushInterval, "how often sinks send what they buffered or aggregated")
+	rootCmd.Flags().BoolVar(&dash, "dashboard", false, "show a full screen dashboard instead of printing reports and alerts. Logs go to the data dir")
+	rootCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "address to serve Prometheus metrics on at /metrics, e.g. :9100. Off by default")
+	rootCmd.Flags().StringVar(&httpAddr, "http-addr", "", "address to serve the web UI, the JSON API under /api/ and the metrics on, e.g. :8080. Off by default")
+	rootCmd.PersistentFlags().StringVar(&dataDir, "data-dir", filepath.Join(os.Getenv("HOME"), ".monidog"), "directory where alert state, silences and history are kept")
+}
+
+// Execute runs the root command
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// parseLog splits the --log flag into the path of the input and how its
+// sections are derived. The spec is the rest of the flag, so that regexes may
+// have commas
+func parseLog(flag string) (path, section string) {
+	if i := strings.Index(flag, ",section="); i >= 0 {
+		return flag[:i], flag[i+len(",section="):]
+	}
+	return flag, ""
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	logger, err := newLogger()
+	if err != nil {
+		return errors.Wrap(err, "failed to create logger")
+	}
+	defer logger.Sync()
+
+	logPath, section := parseLog(logFile)
+	sections, err := reporter.ParseSectionExtractor(section)
+	if err != nil {
+		return errors.Wrapf(err, "invalid sections of %s", logPath)
+	}
+	outputFormat, err := format.ParseFormat(outputName)
+	if err != nil {
+		return err
+	}
+
+	logParser, err := parser.ParseLogFormat(logFormat)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", logPath)
+	}
+	defer f.Close()
+
+	scanner, err := monitor.Watch(f, logParser, 100*time.Millisecond, logger)
+	if err != nil {
+		return errors.Wrap(err, "failed to watch log file")
+	}
+
+	reportWindows, err := reporter.ParseWindows(windows)
+	if err != nil {
+		return err
+	}
+	r := reporter.NewReporter(reportWindows[0])
+	if err := r.SetWindows(reportWindows...); err != nil {
+		return err
+	}
+	r.SetAllowedLateness(lateness)
+	r.SetSectionExtractor(sections)
+	r.SetTopK(topK)
+	r.SetUniqueHorizon(horizon)
+	manager := alerts.NewManager(time.Second)
+	var d *dashboard.Dashboard
+	var out *format.Writer
+	if dash {
+		d = dashboard.New(r, manager.States)
+	} else {
+		out = format.NewWriter(os.Stdout, outputFormat)
+		go out.Print(r.Subscribe())
+	}
+	progress, _ := scanner.(monitor.ProgressReporter)
+	var exporter *metrics.Exporter
+	if metricsAddr != "" || httpAddr != "" {
+		exporter = metrics.New(r, manager.States)
+		if progress != nil {
+			exporter.SetInput(progress)
+		}
+		go exporter.Run()
+	}
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter)
+		srv, err := serve(metricsAddr, mux)
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+	}
+	stopOutputs := []func() error{}
+	for _, spec := range sinks {
+		sink, err := output.ParseSink(spec)
+		if err != nil {
+			return err
+		}
+		var logs <-chan parser.Log
+		if s, ok := sink.(*output.StatsDSink); ok {
+			// only reports are counted, it doesn't subscribe to the logs
+			s.SetWindow(reportWindows[0])
+			s.SetAlerts(manager.States)
+		} else {
+			logs = scanner.Subscribe()
+		}
+		o := output.New(spec, sink)
+		o.SetFlushInterval(flushInterval)
+		stopOutputs = append(stopOutputs, o.Start(logs, r.Subscribe()))
+	}
+	stopReporter := r.Start(scanner.Subscribe())
+
+	silences, err := alerts.OpenSilences(silencesPath())
+	if err != nil {
+		return err
+	}
+	rules, err := loadAlerts()
+	if err != nil {
+		return err
+	}
+	history := alerts.NewHistory(historyPath())
+	var status *api.Server
+	if httpAddr != "" {
+		status = api.New(r, manager.States)
+		if err := status.SetHistory(history); err != nil {
+			return err
+		}
+		if progress != nil {
+			status.AddInput(logPath, progress)
+		}
+		status.Handle("/metrics", exporter)
+		srv, err := serve(httpAddr, status)
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+	}
+	for _, a := range rules {
+		a.SetSilencer(silences)
+		a.OnTransition(history.OnTransition)
+		if status != nil {
+			a.OnTransition(status.OnTransition)
+		}
+		if d != nil {
+			a.SetOutput(nil)
+			a.OnTransition(d.OnTransition)
+		}
+		// alerts print their own messages as text
+		if out != nil && outputFormat != format.Text {
+			a.SetOutput(nil)
+			a.OnTransition(out.OnTransition)
+		}
+		if err := manager.Add(a); err != nil {
+			return err
+		}
+	}
+	persister := alerts.NewPersister(filepath.Join(dataDir, "state.json"), 10*time.Second, rules...)
+	if err := persister.Restore(); err != nil {
+		logger.Warn("failed to restore alert state", zap.Error(err))
+	}
+	if err := manager.Start(scanner.Subscribe()); err != nil {
+		return err
+	}
+	if err := persister.Start(); err != nil {
+		return err
+	}
+
+	if d != nil {
+		if err := d.Run(); err != nil {
+			logger.Error("dashboard failed", zap.Error(err))
+		}
+	} else {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+	}
+
+	if err := persister.Stop(); err != nil {
+		logger.Warn("failed to save alert state", zap.Error(err))
+	}
+	manager.Stop()
+	stopReporter()
+	err = scanner.Close()
+	for _, stop := range stopOutputs {
+		if err := stop(); err != nil {
+			logger.Warn("failed to close output", zap.Error(err))
+		}
+	}
+	return err
+}
+
+// serve serves h on addr in the background
+func serve(addr string, h http.Handler) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on %s", addr)
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(ln)
+	return srv, nil
+}
+
+// newLogger logs to stderr, or to a file in the data dir while the dashboard
+// has the terminal
+func newLogger() (*zap.Logger, error) {
+	if !dash {
+		return zap.NewProduction()
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	c := zap.NewProductionConfig()
+	c.OutputPaths = []string{filepath.Join(dataDir, "monidog.log")}
+	c.ErrorOutputPaths = c.OutputPaths
+	return c.Build()
+}
+
+// loadAlerts builds the alerts declared in the --alerts file, or the default one
+func loadAlerts() ([]*alerts.Alert, error) {
+	if alertsFile == "" {
+		a := alerts.NewAlert("high traffic", 2*time.Minute, 10)
+		a.SetAllowedLateness(lateness)
+		return []*alerts.Alert{a}, nil
+	}
+	c, err := alerts.LoadConfig(alertsFile)
+	if err != nil {
+		return nil, err
+	}
+	return c.Build()
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	silenceMatchers []string
+	silenceStart    string
+	silenceDuration time.Duration
+	silenceComment  string
+)
+
+var silenceCmd = &cobra.Command{
+	Use:   "silence",
+	Short: "Manage alert silences",
+}
+
+var silenceAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Silence the alerts matching all the given matchers",
+	Example: `  monidog silence add --match alertname="high traffic" --duration 30m --comment "deploy"
+  monidog silence add --match env=~prod.* --start 2018-11-10T20:00:00Z --duration 2h`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sil := alerts.Silence{
+			StartsAt: time.Now(),
+			Comment:  silenceComment,
+		}
+		if silenceStart != "" {
+			start, err := time.Parse(time.RFC3339, silenceStart)
+			if err != nil {
+				return errors.Wrap(err, "invalid --start")
+			}
+			sil.StartsAt = start
+		}
+		sil.EndsAt = sil.StartsAt.Add(silenceDuration)
+		for _, s := range silenceMatchers {
+			m, err := alerts.ParseMatcher(s)
+			if err != nil {
+				return err
+			}
+			sil.Matchers = append(sil.Matchers, m)
+		}
+
+		silences, err := alerts.OpenSilences(silencesPath())
+		if err != nil {
+			return err
+		}
+		sil, err = silences.Add(sil)
+		if err != nil {
+			return err
+		}
+		fmt.Println(sil.ID)
+		return nil
+	},
+}
+
+var silenceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List active and upcoming silences",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		silences, err := alerts.OpenSilences(silencesPath())
+		if err != nil {
+			return err
+		}
+		list, err := silences.List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tMATCHERS\tSTARTS\tENDS\tCOMMENT")
+		for _, s := range list {
+			matchers := make([]string, 0, len(s.Matchers))
+			for _, m := range s.Matchers {
+				matchers = append(matchers, m.String())
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				s.ID,
+				strings.Join(matchers, ","),
+				s.StartsAt.Format(time.RFC3339),
+				s.EndsAt.Format(time.RFC3339),
+				s.Comment,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+var silenceExpireCmd = &cobra.Command{
+	Use:   "expire ID...",
+	Short: "End silences before their scheduled end",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		silences, err := alerts.OpenSilences(silencesPath())
+		if err != nil {
+			return err
+		}
+		for _, id := range args {
+			if err := silences.Expire(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	silenceAddCmd.Flags().StringArrayVarP(&silenceMatchers, "match", "m", nil, "matcher as name=value or name=~regex; alertname matches the alert name. Repeatable")
+	silenceAddCmd.Flags().StringVar(&silenceStart, "start", "", "start time in RFC3339 format (default now)")
+	silenceAddCmd.Flags().DurationVarP(&silenceDuration, "duration", "d", time.Hour, "how long the silence lasts")
+	silenceAddCmd.Flags().StringVarP(&silenceComment, "comment", "c", "", "reason for the silence")
+
+	silenceCmd.AddCommand(silenceAddCmd, silenceListCmd, silenceExpireCmd)
+	rootCmd.AddCommand(silenceCmd)
+}
+
+func silencesPath() string {
+	return filepath.Join(dataDir, "silences.json")
+}
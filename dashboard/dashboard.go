@@ -0,0 +1,291 @@
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jroimartin/gocui"
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/pkg/errors"
+)
+
+// how many reports the request rate sparkline remembers per window
+const maxRates = 120
+
+// how many alert transitions are shown
+const maxTransitions = 20
+
+// Dashboard is a full screen terminal UI showing the reports of a reporter and
+// the state of alerts. It reads them through their APIs rather than their
+// printed output, so they should be told not to print while it runs
+type Dashboard struct {
+	reports <-chan reporter.Report
+	states  func() []alerts.State
+
+	mu          sync.Mutex
+	windows     []time.Duration
+	window      int
+	latest      map[time.Duration]reporter.Report
+	rates       map[time.Duration][]int
+	transitions []alerts.Transition
+	sort        SortMode
+	filter      string
+	paused      bool
+}
+
+// New constructs a dashboard for a reporter, which must not be started yet.
+// The alerts panel is redrawn from states on every refresh; nil leaves it empty
+func New(r *reporter.Reporter, states func() []alerts.State) *Dashboard {
+	d := Dashboard{
+		reports: r.Subscribe(),
+		states:  states,
+		windows: r.Windows(),
+		latest:  map[time.Duration]reporter.Report{},
+		rates:   map[time.Duration][]int{},
+	}
+	return &d
+}
+
+// OnTransition records an alert transition for the alerts panel. Register it
+// with Alert.OnTransition
+func (d *Dashboard) OnTransition(t alerts.Transition) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.transitions = append(d.transitions, t)
+	if len(d.transitions) > maxTransitions {
+		d.transitions = d.transitions[len(d.transitions)-maxTransitions:]
+	}
+}
+
+// receive records a report
+func (d *Dashboard) receive(rep reporter.Report) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latest[rep.Window] = rep
+	rates := append(d.rates[rep.Window], rep.Total.Hits)
+	if len(rates) > maxRates {
+		rates = rates[len(rates)-maxRates:]
+	}
+	d.rates[rep.Window] = rates
+}
+
+// TogglePause freezes or unfreezes the screen. Reports keep being recorded
+func (d *Dashboard) TogglePause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paused = !d.paused
+}
+
+// NextSort cycles through the orders of the sections table
+func (d *Dashboard) NextSort() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sort = (d.sort + 1) % (ByName + 1)
+}
+
+// NextWindow cycles through the report windows shown
+func (d *Dashboard) NextWindow() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window = (d.window + 1) % len(d.windows)
+}
+
+// SetFilter only shows the sections containing filter
+func (d *Dashboard) SetFilter(filter string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.filter = strings.TrimSpace(filter)
+}
+
+// screen is the text of every panel
+type screen struct {
+	title    string
+	rate     string
+	sections []string
+	alerts   []string
+}
+
+// render builds the text of the panels, the rate sparkline width wide
+func (d *Dashboard) render(width int) screen {
+	d.mu.Lock()
+	w := d.windows[d.window]
+	rep, ok := d.latest[w]
+	rates := append([]int(nil), d.rates[w]...)
+	transitions := append([]alerts.Transition(nil), d.transitions...)
+	mode, filter, paused := d.sort, d.filter, d.paused
+	d.mu.Unlock()
+
+	s := screen{title: fmt.Sprintf(" window %s | sort %s ", w, mode)}
+	if filter != "" {
+		s.title += fmt.Sprintf("| filter %q ", filter)
+	}
+	if paused {
+		s.title += "| PAUSED "
+	}
+	if ok {
+		s.title += fmt.Sprintf("| %s - %s ", rep.Start.Format("15:04:05"), rep.End.Format("15:04:05"))
+		last := 0
+		if len(rates) > 0 {
+			last = rates[len(rates)-1]
+		}
+		s.rate = fmt.Sprintf("%s %.1f req/s", sparkline(rates, width-16), float64(last)/w.Seconds())
+	}
+	s.sections = sectionRows(rep, mode, filter)
+	if d.states != nil {
+		s.alerts = alertRows(d.states(), transitions)
+	}
+	return s
+}
+
+// Run shows the dashboard until q or ctrl-c is pressed
+func (d *Dashboard) Run() error {
+	g, err := gocui.NewGui(gocui.OutputNormal)
+	if err != nil {
+		return errors.Wrap(err, "failed to start the dashboard")
+	}
+	defer g.Close()
+
+	g.InputEsc = true
+	g.SetManagerFunc(d.layout)
+	if err := d.keybindings(g); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go d.loop(g, done)
+
+	if err := g.MainLoop(); err != nil && err != gocui.ErrQuit {
+		return errors.Wrap(err, "dashboard failed")
+	}
+	return nil
+}
+
+// loop records reports as they come and redraws every second, for the alerts
+func (d *Dashboard) loop(g *gocui.Gui, done <-chan struct{}) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	redraw := func(*gocui.Gui) error { return nil }
+	for {
+		select {
+		case rep, ok := <-d.reports:
+			if !ok {
+				d.reports = nil
+				continue
+			}
+			d.receive(rep)
+			g.Update(redraw)
+		case <-t.C:
+			g.Update(redraw)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (d *Dashboard) layout(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+	d.mu.Lock()
+	paused := d.paused
+	d.mu.Unlock()
+
+	rate, err := g.SetView("rate", 0, 0, maxX-1, 2)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	sections, err := g.SetView("sections", 0, 3, maxX*2/3-1, maxY-1)
+	if err == gocui.ErrUnknownView {
+		// keys go to the sections view unless the filter is being edited
+		if _, err := g.SetCurrentView("sections"); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	alertsView, err := g.SetView("alerts", maxX*2/3, 3, maxX-1, maxY-1)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	alertsView.Title = " alerts "
+
+	s := d.render(maxX - 2)
+	rate.Title = s.title
+	if paused {
+		return nil
+	}
+	rate.Clear()
+	fmt.Fprint(rate, s.rate)
+	sections.Title = " sections  [p]ause [s]ort [w]indow [/]filter [q]uit "
+	sections.Clear()
+	fmt.Fprint(sections, strings.Join(s.sections, "\n"))
+	alertsView.Clear()
+	fmt.Fprint(alertsView, strings.Join(s.alerts, "\n"))
+	return nil
+}
+
+func (d *Dashboard) keybindings(g *gocui.Gui) error {
+	quit := func(*gocui.Gui, *gocui.View) error { return gocui.ErrQuit }
+	do := func(f func()) func(*gocui.Gui, *gocui.View) error {
+		return func(*gocui.Gui, *gocui.View) error {
+			f()
+			return nil
+		}
+	}
+	bindings := []struct {
+		view    string
+		key     interface{}
+		handler func(*gocui.Gui, *gocui.View) error
+	}{
+		{"", gocui.KeyCtrlC, quit},
+		{"sections", 'q', quit},
+		{"sections", 'p', do(d.TogglePause)},
+		{"sections", 's', do(d.NextSort)},
+		{"sections", 'w', do(d.NextWindow)},
+		{"sections", '/', d.editFilter},
+		{"filter", gocui.KeyEnter, d.applyFilter},
+		{"filter", gocui.KeyEsc, d.closeFilter},
+	}
+	for _, b := range bindings {
+		if err := g.SetKeybinding(b.view, b.key, gocui.ModNone, b.handler); err != nil {
+			return errors.Wrap(err, "failed to set dashboard keys")
+		}
+	}
+	return nil
+}
+
+// editFilter opens a prompt for the sections filter
+func (d *Dashboard) editFilter(g *gocui.Gui, _ *gocui.View) error {
+	maxX, maxY := g.Size()
+	v, err := g.SetView("filter", maxX/4, maxY/2-1, maxX*3/4, maxY/2+1)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	v.Title = " filter sections (enter to apply, esc to cancel) "
+	v.Editable = true
+	d.mu.Lock()
+	filter := d.filter
+	d.mu.Unlock()
+	v.Clear()
+	fmt.Fprint(v, filter)
+	v.SetCursor(len(filter), 0)
+	g.Cursor = true
+	_, err = g.SetCurrentView("filter")
+	return err
+}
+
+func (d *Dashboard) applyFilter(g *gocui.Gui, v *gocui.View) error {
+	d.SetFilter(v.Buffer())
+	return d.closeFilter(g, v)
+}
+
+func (d *Dashboard) closeFilter(g *gocui.Gui, _ *gocui.View) error {
+	g.Cursor = false
+	if err := g.DeleteView("filter"); err != nil {
+		return err
+	}
+	_, err := g.SetCurrentView("sections")
+	return err
+}
@@ -0,0 +1,148 @@
+package dashboard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/reporter"
+)
+
+// SortMode is the order of the sections table
+type SortMode int
+
+// Section orders, cycled through with the s key
+const (
+	ByHits SortMode = iota
+	ByErrors
+	ByTrend
+	ByName
+)
+
+func (m SortMode) String() string {
+	switch m {
+	case ByErrors:
+		return "errors"
+	case ByTrend:
+		return "trend"
+	case ByName:
+		return "name"
+	}
+	return "hits"
+}
+
+// PendingRatio is how close to its threshold an alert's count has to be to show
+// as pending
+const PendingRatio = 0.8
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the last width values scaled to the highest one
+func sparkline(values []int, width int) string {
+	if width > 0 && len(values) > width {
+		values = values[len(values)-width:]
+	}
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		if max == 0 {
+			line[i] = sparks[0]
+			continue
+		}
+		line[i] = sparks[v*(len(sparks)-1)/max]
+	}
+	return string(line)
+}
+
+// sectionRows formats the sections of a report matching filter, in the given
+// order, as table rows
+func sectionRows(rep reporter.Report, mode SortMode, filter string) []string {
+	sections := []reporter.SectionReport{}
+	for _, s := range rep.Sections {
+		if filter == "" || strings.Contains(s.Name, filter) {
+			sections = append(sections, s)
+		}
+	}
+	delta := func(s reporter.SectionReport) int {
+		if rep.Trend == nil {
+			return 0
+		}
+		t, _ := rep.Trend.Section(s.Name)
+		return t.Delta
+	}
+	sort.SliceStable(sections, func(i, j int) bool {
+		a, b := sections[i], sections[j]
+		switch mode {
+		case ByErrors:
+			if a.Stats.ErrorPercent() != b.Stats.ErrorPercent() {
+				return a.Stats.ErrorPercent() > b.Stats.ErrorPercent()
+			}
+		case ByTrend:
+			if delta(a) != delta(b) {
+				return delta(a) > delta(b)
+			}
+		case ByName:
+			return a.Name < b.Name
+		}
+		// reports are ordered by hits then name already
+		return false
+	})
+
+	rows := []string{fmt.Sprintf("%-30s %8s %7s %14s", "SECTION", "HITS", "ERRORS", "TREND")}
+	for _, s := range sections {
+		trend := ""
+		if rep.Trend != nil {
+			t, _ := rep.Trend.Section(s.Name)
+			if t.New() {
+				trend = "new"
+			} else {
+				trend = fmt.Sprintf("%+d %+.0f%%", t.Delta, t.Change)
+			}
+		}
+		rows = append(rows, fmt.Sprintf("%-30s %8d %6.1f%% %14s", s.Name, s.Hits, s.Stats.ErrorPercent(), trend))
+	}
+	return rows
+}
+
+// alertRows formats the state of the alerts, active first then pending, followed
+// by the most recent transitions
+func alertRows(states []alerts.State, transitions []alerts.Transition) []string {
+	var active, pending, ok []string
+	for _, s := range states {
+		switch {
+		case s.Active:
+			value := strconv.Itoa(s.Count)
+			if s.Expr != "" {
+				value = alerts.FormatValues(s.Values)
+			}
+			active = append(active, fmt.Sprintf("ACTIVE    %s since %s (%s)", s.Name, s.Since.Format("15:04:05"), value))
+		case s.Threshold > 0 && float64(s.Count) >= PendingRatio*float64(s.Threshold):
+			pending = append(pending, fmt.Sprintf("PENDING   %s %d/%d", s.Name, s.Count, s.Threshold))
+		default:
+			ok = append(ok, fmt.Sprintf("ok        %s", s.Name))
+		}
+	}
+	rows := append(append(active, pending...), ok...)
+	for i := len(transitions) - 1; i >= 0; i-- {
+		t := transitions[i]
+		switch t.Event {
+		case alerts.Recovered:
+			rows = append(rows, fmt.Sprintf("%s recovered %s after %s", t.At.Format("15:04:05"), t.Name, t.Duration.Round(time.Second)))
+		case alerts.Fired:
+			value := strconv.Itoa(t.Value)
+			if t.Expr != "" {
+				value = alerts.FormatValues(t.Values)
+			}
+			rows = append(rows, fmt.Sprintf("%s fired     %s (%s)", t.At.Format("15:04:05"), t.Name, value))
+		}
+	}
+	return rows
+}
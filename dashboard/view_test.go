@@ -0,0 +1,113 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/mihaichiorean/monidog/reporter/reportertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sparkline(t *testing.T) {
+	assert.Equal(t, "", sparkline(nil, 10))
+	assert.Equal(t, "▁▁▁", sparkline([]int{0, 0, 0}, 10))
+	assert.Equal(t, "▁▄█", sparkline([]int{0, 5, 10}, 10))
+	// only the last width values are drawn
+	assert.Equal(t, "▄█", sparkline([]int{100, 5, 10}, 2))
+}
+
+func Test_sectionRows(t *testing.T) {
+	prev := reportertest.Report(reportertest.Start, 10*time.Second,
+		reportertest.Section("/api", map[string]int{"2xx": 10}),
+		reportertest.Section("/users", map[string]int{"2xx": 2}))
+	rep := reportertest.Report(prev.End, 10*time.Second,
+		reportertest.Section("/api", map[string]int{"2xx": 5, "5xx": 5}),
+		reportertest.Section("/users", map[string]int{"2xx": 8}),
+		reportertest.Section("/admin", map[string]int{"5xx": 1}))
+	tr := reporter.Compare(prev, rep)
+	rep.Trend = &tr
+
+	names := func(rows []string) []string {
+		n := []string{}
+		for _, r := range rows[1:] {
+			n = append(n, r[:7])
+		}
+		return n
+	}
+	rows := sectionRows(rep, ByHits, "")
+	assert.Contains(t, rows[0], "SECTION")
+	assert.Equal(t, []string{"/api   ", "/users ", "/admin "}, names(rows))
+	assert.Contains(t, rows[1], "50.0%")
+	assert.Contains(t, rows[2], "+6 +300%")
+	assert.Contains(t, rows[3], "new")
+
+	assert.Equal(t, []string{"/admin ", "/api   ", "/users "}, names(sectionRows(rep, ByErrors, "")))
+	assert.Equal(t, []string{"/users ", "/admin ", "/api   "}, names(sectionRows(rep, ByTrend, "")))
+	assert.Equal(t, []string{"/admin ", "/api   ", "/users "}, names(sectionRows(rep, ByName, "")))
+	assert.Equal(t, []string{"/users "}, names(sectionRows(rep, ByHits, "/u")))
+	assert.Len(t, sectionRows(reporter.Report{}, ByHits, ""), 1)
+}
+
+func Test_alertRows(t *testing.T) {
+	since := reportertest.Start
+	states := []alerts.State{
+		{Name: "quiet", Count: 1, Threshold: 10},
+		{Name: "close", Count: 8, Threshold: 10},
+		{Name: "firing", Active: true, Count: 12, Threshold: 10, Since: since},
+	}
+	transitions := []alerts.Transition{
+		{Name: "firing", Event: alerts.Fired, At: since, Value: 11},
+		{Name: "old", Event: alerts.Recovered, At: since.Add(time.Minute), Duration: 90 * time.Second},
+	}
+	assert.Equal(t, []string{
+		"ACTIVE    firing since 12:00:00 (12)",
+		"PENDING   close 8/10",
+		"ok        quiet",
+		"12:01:00 recovered old after 1m30s",
+		"12:00:00 fired     firing (11)",
+	}, alertRows(states, transitions))
+}
+
+func Test_Dashboard(t *testing.T) {
+	r := reporter.NewReporter(10 * time.Second)
+	assert.NoError(t, r.SetWindows(10*time.Second, time.Minute))
+	states := []alerts.State{{Name: "high traffic", Threshold: 10}}
+	d := New(r, func() []alerts.State { return states })
+
+	for i := 1; i <= maxRates+5; i++ {
+		d.receive(reportertest.Report(reportertest.Start, 10*time.Second,
+			reportertest.Section("/api", map[string]int{"2xx": i})))
+	}
+	assert.Len(t, d.rates[10*time.Second], maxRates)
+
+	s := d.render(80)
+	assert.Contains(t, s.title, "window 10s")
+	assert.Contains(t, s.title, "sort hits")
+	assert.Contains(t, s.rate, "12.5 req/s")
+	assert.Len(t, s.sections, 2)
+	assert.Equal(t, []string{"ok        high traffic"}, s.alerts)
+
+	d.NextSort()
+	d.TogglePause()
+	d.SetFilter(" /users ")
+	s = d.render(80)
+	assert.Contains(t, s.title, "sort errors")
+	assert.Contains(t, s.title, `filter "/users"`)
+	assert.Contains(t, s.title, "PAUSED")
+	assert.Len(t, s.sections, 1)
+
+	// no minute report yet
+	d.NextWindow()
+	s = d.render(80)
+	assert.Contains(t, s.title, "window 1m0s")
+	assert.Equal(t, "", s.rate)
+	d.NextWindow()
+	assert.Contains(t, d.render(80).title, "window 10s")
+
+	for i := 0; i < maxTransitions+1; i++ {
+		d.OnTransition(alerts.Transition{Name: "high traffic", Event: alerts.Fired})
+	}
+	assert.Len(t, d.transitions, maxTransitions)
+}
@@ -0,0 +1,165 @@
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/pkg/errors"
+)
+
+// Format is how reports and alert transitions are written
+type Format string
+
+// Formats, see the README for their schemas
+const (
+	Text   Format = "text"
+	JSON   Format = "json"
+	CSV    Format = "csv"
+	Logfmt Format = "logfmt"
+)
+
+// ParseFormat parses the name of a format
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(name))); f {
+	case Text, JSON, CSV, Logfmt:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, expected text, json, csv or logfmt", name)
+}
+
+// Writer writes reports and alert transitions in a format. It is safe for
+// concurrent use, so reports and transitions can share an output
+type Writer struct {
+	format Format
+
+	mu     sync.Mutex
+	w      io.Writer
+	csv    *csv.Writer
+	header bool
+}
+
+// NewWriter constructs a writer of the given format to w
+func NewWriter(w io.Writer, f Format) *Writer {
+	wr := Writer{
+		format: f,
+		w:      w,
+	}
+	if f == CSV {
+		wr.csv = csv.NewWriter(w)
+	}
+	return &wr
+}
+
+// reportLine and alertLine are the JSON lines of reports and transitions
+type reportLine struct {
+	Type string `json:"type"`
+	reporter.Report
+}
+
+type alertLine struct {
+	Type string `json:"type"`
+	alerts.Transition
+}
+
+// Report writes a report
+func (w *Writer) Report(rep reporter.Report) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch w.format {
+	case JSON:
+		return w.json(reportLine{Type: "report", Report: rep})
+	case CSV, Logfmt:
+		records := make([]record, 0, len(rep.Sections)+1)
+		for _, s := range rep.Sections {
+			records = append(records, sectionRecord(rep, s))
+		}
+		records = append(records, totalRecord(rep))
+		return w.records(records...)
+	}
+	reporter.PrintReport(w.w, rep)
+	return nil
+}
+
+// Transition writes an alert transition
+func (w *Writer) Transition(t alerts.Transition) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch w.format {
+	case JSON:
+		return w.json(alertLine{Type: "alert", Transition: t})
+	case CSV, Logfmt:
+		return w.records(alertRecord(t))
+	}
+	_, err := fmt.Fprintln(w.w, formatTransition(t))
+	return errors.Wrap(err, "failed to write transition")
+}
+
+// Print writes the reports received on a subscription until it closes
+func (w *Writer) Print(reports <-chan reporter.Report) {
+	for rep := range reports {
+		if err := w.Report(rep); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}
+}
+
+// OnTransition can be registered on an alert with Alert.OnTransition. Silenced
+// transitions are left out, like alerts leave them out of their own messages
+func (w *Writer) OnTransition(t alerts.Transition) {
+	if t.Silenced {
+		return
+	}
+	if err := w.Transition(t); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+func (w *Writer) json(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode json line")
+	}
+	_, err = w.w.Write(append(b, '\n'))
+	return errors.Wrap(err, "failed to write json line")
+}
+
+func (w *Writer) records(records ...record) error {
+	if w.format == Logfmt {
+		for _, r := range records {
+			if _, err := fmt.Fprintln(w.w, r.logfmt()); err != nil {
+				return errors.Wrap(err, "failed to write logfmt line")
+			}
+		}
+		return nil
+	}
+	if !w.header {
+		w.csv.Write(columns)
+		w.header = true
+	}
+	for _, r := range records {
+		w.csv.Write(r.csv())
+	}
+	w.csv.Flush()
+	return errors.Wrap(w.csv.Error(), "failed to write csv")
+}
+
+// formatTransition describes a transition on one line, e.g.
+// "12:00:00 high traffic fired - hits = 11, threshold = 10", or for compound
+// alerts "12:00:00 errors fired - errors / all > 0.1 with all=20 errors=3"
+func formatTransition(t alerts.Transition) string {
+	line := fmt.Sprintf("%s %s %s - hits = %d, threshold = %d", t.At.Format("15:04:05"), t.Name, t.Event, t.Value, t.Threshold)
+	if t.Expr != "" {
+		line = fmt.Sprintf("%s %s %s - %s with %s", t.At.Format("15:04:05"), t.Name, t.Event, t.Expr, alerts.FormatValues(t.Values))
+	}
+	if t.Event == alerts.Recovered {
+		line += fmt.Sprintf(", active for %s", t.Duration)
+	}
+	return line
+}
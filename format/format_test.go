@@ -0,0 +1,115 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/model"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/mihaichiorean/monidog/reporter/reportertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func testReport() reporter.Report {
+	api := reportertest.Section("/api", map[string]int{"2xx": 3, "5xx": 1})
+	api.UniqueClients, api.UniqueURLs = 2, 3
+	users := reportertest.Section("/my users", map[string]int{"2xx": 1})
+	users.UniqueClients, users.UniqueURLs = 1, 1
+	rep := reportertest.Report(reportertest.Start, 10*time.Second, api, users)
+	rep.Top = map[string][]model.KeyCount{}
+	rep.UniqueClients, rep.UniqueURLs = 3, 4
+	return rep
+}
+
+var fired = alerts.Transition{Name: "high traffic", Event: alerts.Fired, At: reportertest.Start.Add(time.Second), Value: 11, Threshold: 10}
+var recovered = alerts.Transition{Name: "high traffic", Event: alerts.Recovered, At: reportertest.Start.Add(time.Minute), Value: 9, Threshold: 10, Duration: 59 * time.Second}
+
+func Test_ParseFormat(t *testing.T) {
+	for _, name := range []string{"text", "json", "CSV", " logfmt "} {
+		_, err := ParseFormat(name)
+		assert.NoError(t, err)
+	}
+	_, err := ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func Test_Writer_JSON(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewWriter(b, JSON)
+	assert.NoError(t, w.Report(testReport()))
+	assert.NoError(t, w.Transition(fired))
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(t, lines, 2)
+	var rep map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &rep))
+	assert.Equal(t, "report", rep["type"])
+	assert.Equal(t, "2018-11-10T12:00:00Z", rep["start"])
+	assert.Equal(t, float64(10*time.Second), rep["window"])
+	assert.Equal(t, "/api", rep["sections"].([]interface{})[0].(map[string]interface{})["name"])
+	assert.Equal(t, `{"type":"alert","name":"high traffic","event":"fired","at":"2018-11-10T12:00:01Z","value":11,"threshold":10}`, lines[1])
+}
+
+func Test_Writer_CSV(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewWriter(b, CSV)
+	assert.NoError(t, w.Report(testReport()))
+	assert.NoError(t, w.Transition(recovered))
+	assert.NoError(t, w.Report(reportertest.Report(reportertest.Start, 10*time.Second)))
+	assert.Equal(t, strings.Join([]string{
+		"type,start,end,window,name,hits,status_2xx,status_3xx,status_4xx,status_5xx,bytes,error_percent,unique_clients,unique_urls,event,value,threshold,duration,expr,values",
+		"section,2018-11-10T12:00:00Z,2018-11-10T12:00:10Z,10s,/api,4,3,0,0,1,400,25.0,2,3,,,,,,",
+		"section,2018-11-10T12:00:00Z,2018-11-10T12:00:10Z,10s,/my users,1,1,0,0,0,100,0.0,1,1,,,,,,",
+		"total,2018-11-10T12:00:00Z,2018-11-10T12:00:10Z,10s,/api,5,4,0,0,1,500,20.0,3,4,,,,,,",
+		"alert,2018-11-10T12:01:00Z,,,high traffic,,,,,,,,,,recovered,9,10,59s,,",
+		"total,2018-11-10T12:00:00Z,2018-11-10T12:00:10Z,10s,,0,0,0,0,0,0,0.0,0,0,,,,,,",
+	}, "\n")+"\n", b.String())
+}
+
+func Test_Writer_Logfmt(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewWriter(b, Logfmt)
+	assert.NoError(t, w.Report(testReport()))
+	w.OnTransition(fired)
+	silenced := fired
+	silenced.Silenced = true
+	w.OnTransition(silenced)
+	assert.Equal(t, strings.Join([]string{
+		"type=section start=2018-11-10T12:00:00Z end=2018-11-10T12:00:10Z window=10s name=/api hits=4 status_2xx=3 status_3xx=0 status_4xx=0 status_5xx=1 bytes=400 error_percent=25.0 unique_clients=2 unique_urls=3",
+		`type=section start=2018-11-10T12:00:00Z end=2018-11-10T12:00:10Z window=10s name="/my users" hits=1 status_2xx=1 status_3xx=0 status_4xx=0 status_5xx=0 bytes=100 error_percent=0.0 unique_clients=1 unique_urls=1`,
+		"type=total start=2018-11-10T12:00:00Z end=2018-11-10T12:00:10Z window=10s name=/api hits=5 status_2xx=4 status_3xx=0 status_4xx=0 status_5xx=1 bytes=500 error_percent=20.0 unique_clients=3 unique_urls=4",
+		`type=alert start=2018-11-10T12:00:01Z name="high traffic" event=fired value=11 threshold=10`,
+	}, "\n")+"\n", b.String())
+}
+
+func Test_Writer_Text(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewWriter(b, Text)
+	assert.NoError(t, w.Report(testReport()))
+	assert.NoError(t, w.Transition(recovered))
+	assert.Contains(t, b.String(), "-------------------------------------- 12:00:00 - 12:00:10 (10s)\n")
+	assert.Contains(t, b.String(), "12:01:00 high traffic recovered - hits = 9, threshold = 10, active for 59s\n")
+}
+
+func Test_Writer_compound(t *testing.T) {
+	compound := alerts.Transition{
+		Name:   "errors",
+		Event:  alerts.Fired,
+		At:     reportertest.Start.Add(time.Second),
+		Value:  20,
+		Expr:   "errors / all > 0.1",
+		Values: map[string]float64{"all": 20, "errors": 3},
+	}
+
+	b := &bytes.Buffer{}
+	assert.NoError(t, NewWriter(b, Text).Transition(compound))
+	assert.Equal(t, "12:00:01 errors fired - errors / all > 0.1 with all=20 errors=3\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, NewWriter(b, Logfmt).Transition(compound))
+	assert.Equal(t, `type=alert start=2018-11-10T12:00:01Z name=errors event=fired value=20 expr="errors / all > 0.1" values="all=20 errors=3"`+"\n", b.String())
+}
@@ -0,0 +1,110 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/model"
+	"github.com/mihaichiorean/monidog/reporter"
+)
+
+// columns are the fields of CSV rows and logfmt lines, in order. Fields that
+// don't apply to a record type are empty in CSV and left out of logfmt
+var columns = []string{
+	"type", "start", "end", "window", "name", "hits",
+	"status_2xx", "status_3xx", "status_4xx", "status_5xx",
+	"bytes", "error_percent", "unique_clients", "unique_urls",
+	"event", "value", "threshold", "duration", "expr", "values",
+}
+
+// record is a flat row: a section or the total of a report, or an alert
+// transition
+type record map[string]string
+
+func statsRecord(typ string, rep reporter.Report, name string, s model.Stats) record {
+	r := record{
+		"type":          typ,
+		"start":         formatTime(rep.Start),
+		"end":           formatTime(rep.End),
+		"window":        rep.Window.String(),
+		"name":          name,
+		"hits":          strconv.Itoa(s.Hits),
+		"bytes":         strconv.FormatUint(s.Bytes, 10),
+		"error_percent": strconv.FormatFloat(s.ErrorPercent(), 'f', 1, 64),
+	}
+	for _, class := range []string{"2xx", "3xx", "4xx", "5xx"} {
+		r["status_"+class] = strconv.Itoa(s.Statuses[class])
+	}
+	return r
+}
+
+func sectionRecord(rep reporter.Report, s reporter.SectionReport) record {
+	r := statsRecord("section", rep, s.Name, s.Stats)
+	r["unique_clients"] = strconv.FormatUint(s.UniqueClients, 10)
+	r["unique_urls"] = strconv.FormatUint(s.UniqueURLs, 10)
+	return r
+}
+
+// totalRecord is named after the hot section of the report
+func totalRecord(rep reporter.Report) record {
+	r := statsRecord("total", rep, rep.HotSection, rep.Total)
+	r["unique_clients"] = strconv.FormatUint(rep.UniqueClients, 10)
+	r["unique_urls"] = strconv.FormatUint(rep.UniqueURLs, 10)
+	return r
+}
+
+func alertRecord(t alerts.Transition) record {
+	r := record{
+		"type":      "alert",
+		"start":     formatTime(t.At),
+		"name":      t.Name,
+		"event":     string(t.Event),
+		"value":     strconv.Itoa(t.Value),
+		"threshold": strconv.Itoa(t.Threshold),
+	}
+	if t.Expr != "" {
+		// compound alerts have no threshold, their conditions are compared
+		delete(r, "threshold")
+		r["expr"] = t.Expr
+		r["values"] = alerts.FormatValues(t.Values)
+	}
+	if t.Event == alerts.Recovered {
+		r["duration"] = t.Duration.String()
+	}
+	return r
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (r record) csv() []string {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = r[c]
+	}
+	return row
+}
+
+func (r record) logfmt() string {
+	pairs := []string{}
+	for _, c := range columns {
+		if v, ok := r[c]; ok && v != "" {
+			pairs = append(pairs, c+"="+logfmtValue(v))
+		}
+	}
+	return strings.Join(pairs, " ")
+}
+
+// logfmtValue quotes values with spaces, quotes or equal signs
+func logfmtValue(v string) string {
+	if strings.ContainsAny(v, " \t\"=\\\n") {
+		return strconv.Quote(v)
+	}
+	return v
+}
@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/model"
+	"github.com/mihaichiorean/monidog/monitor"
+	"github.com/mihaichiorean/monidog/reporter"
+)
+
+// Exporter serves metrics about the traffic in the logs, the scanner reading
+// them, the reporter and the alerts in the Prometheus text format. Traffic
+// counters are the sums of the reports of the reporter's first window, so they
+// move once per report
+type Exporter struct {
+	reports <-chan reporter.Report
+	window  time.Duration
+	dropped func() int
+	states  func() []alerts.State
+	input   monitor.ProgressReporter
+
+	mu          sync.Mutex
+	sections    map[string]model.Stats
+	droppedLate int
+}
+
+// New constructs an exporter for a reporter, which must not be started yet.
+// states is called on every scrape for the alert gauges
+func New(r *reporter.Reporter, states func() []alerts.State) *Exporter {
+	e := Exporter{
+		reports:  r.Subscribe(),
+		window:   r.Windows()[0],
+		dropped:  r.Dropped,
+		states:   states,
+		sections: map[string]model.Stats{},
+	}
+	return &e
+}
+
+// SetInput sets the scanner whose lines, parse errors and lag are exported
+func (e *Exporter) SetInput(s monitor.ProgressReporter) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.input = s
+}
+
+// Run records reports until the reporter stops
+func (e *Exporter) Run() {
+	for rep := range e.reports {
+		e.receive(rep)
+	}
+}
+
+// receive adds the hits of a report to the counters
+func (e *Exporter) receive(rep reporter.Report) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.droppedLate = rep.DroppedLate
+	// coarser windows are rolled up from the first one and would count hits twice
+	if rep.Window != e.window {
+		return
+	}
+	for _, s := range rep.Sections {
+		st, ok := e.sections[s.Name]
+		if !ok {
+			st = model.NewStats()
+		}
+		st.Merge(s.Stats)
+		e.sections[s.Name] = st
+	}
+}
+
+// ServeHTTP writes the metrics
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	e.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text format
+func (e *Exporter) Write(w io.Writer) {
+	e.mu.Lock()
+	sections := make([]string, 0, len(e.sections))
+	for name := range e.sections {
+		sections = append(sections, name)
+	}
+	sort.Strings(sections)
+	stats := make([]model.Stats, len(sections))
+	for i, name := range sections {
+		stats[i] = e.sections[name].Copy()
+	}
+	droppedLate, input := e.droppedLate, e.input
+	e.mu.Unlock()
+
+	m := writer{w: w}
+	m.family("monidog_requests_total", "counter", "Requests seen in the logs by section")
+	for i, name := range sections {
+		m.sample("monidog_requests_total", stats[i].Hits, "section", name)
+	}
+	m.family("monidog_responses_total", "counter", "Requests by section and status class")
+	for i, name := range sections {
+		for _, k := range sortedKeys(stats[i].Statuses) {
+			m.sample("monidog_responses_total", stats[i].Statuses[k], "section", name, "status", k)
+		}
+	}
+	m.family("monidog_requests_by_method_total", "counter", "Requests by section and method")
+	for i, name := range sections {
+		for _, k := range sortedKeys(stats[i].Methods) {
+			m.sample("monidog_requests_by_method_total", stats[i].Methods[k], "section", name, "method", k)
+		}
+	}
+	m.family("monidog_response_bytes_total", "counter", "Response bytes by section")
+	for i, name := range sections {
+		m.sample("monidog_response_bytes_total", stats[i].Bytes, "section", name)
+	}
+	m.family("monidog_late_logs_dropped_total", "counter", "Logs that arrived after their window was reported")
+	m.sample("monidog_late_logs_dropped_total", droppedLate)
+	m.family("monidog_subscriber_reports_dropped_total", "counter", "Reports dropped for subscribers that fell behind")
+	m.sample("monidog_subscriber_reports_dropped_total", e.dropped())
+
+	if input != nil {
+		s := input.Stats()
+		m.family("monidog_lines_total", "counter", "Log lines parsed")
+		m.sample("monidog_lines_total", s.Lines)
+		m.family("monidog_parse_errors_total", "counter", "Log lines that failed to parse")
+		m.sample("monidog_parse_errors_total", s.ParseErrors)
+		m.family("monidog_scanner_offset_bytes", "gauge", "Position of the scanner in the log file")
+		m.sample("monidog_scanner_offset_bytes", s.Offset)
+		m.family("monidog_scanner_lag_bytes", "gauge", "Bytes written to the log file that were not read yet")
+		m.sample("monidog_scanner_lag_bytes", s.Lag())
+	}
+
+	if e.states == nil {
+		return
+	}
+	states := e.states()
+	m.family("monidog_alert_active", "gauge", "Whether an alert is firing")
+	for _, s := range states {
+		active := 0
+		if s.Active {
+			active = 1
+		}
+		m.sample("monidog_alert_active", active, "alert", s.Name)
+	}
+	m.family("monidog_alert_count", "gauge", "Count of an alert in its window")
+	for _, s := range states {
+		m.sample("monidog_alert_count", s.Count, "alert", s.Name)
+	}
+	m.family("monidog_alert_threshold", "gauge", "Count an alert fires above, 0 for compound alerts")
+	for _, s := range states {
+		m.sample("monidog_alert_threshold", s.Threshold, "alert", s.Name)
+	}
+	m.family("monidog_alert_condition_value", "gauge", "Count of a condition of a compound alert in its window")
+	for _, s := range states {
+		names := make([]string, 0, len(s.Values))
+		for n := range s.Values {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			m.sample("monidog_alert_condition_value", s.Values[n], "alert", s.Name, "condition", n)
+		}
+	}
+}
+
+// writer writes metrics in the Prometheus text format
+type writer struct {
+	w io.Writer
+}
+
+func (m writer) family(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a value with labels given as name, value pairs
+func (m writer) sample(name string, value interface{}, labels ...string) {
+	if len(labels) == 0 {
+		fmt.Fprintf(m.w, "%s %v\n", name, value)
+		return
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escape(labels[i+1])))
+	}
+	fmt.Fprintf(m.w, "%s{%s} %v\n", name, strings.Join(pairs, ","), value)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes a label value
+func escape(v string) string {
+	return escaper.Replace(v)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
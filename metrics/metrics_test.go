@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/monitor"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/mihaichiorean/monidog/reporter/reportertest"
+	"github.com/stretchr/testify/assert"
+)
+
+type input monitor.Stats
+
+func (i input) Stats() monitor.Stats {
+	return monitor.Stats(i)
+}
+
+func Test_Exporter(t *testing.T) {
+	r := reporter.NewReporter(10 * time.Second)
+	assert.NoError(t, r.SetWindows(10*time.Second, time.Minute))
+	states := []alerts.State{
+		{Name: "high traffic", Active: true, Count: 12, Threshold: 10},
+		{Name: `say "hi"`, Count: 1, Threshold: 5},
+		{Name: "api errors", Count: 20, Expr: "errors > 2", Values: map[string]float64{"errors": 3}},
+	}
+	e := New(r, func() []alerts.State { return states })
+	e.SetInput(input{Offset: 100, Size: 150, Lines: 7, ParseErrors: 2})
+
+	users := reportertest.Section("/users", map[string]int{"5xx": 1})
+	users.Stats.Methods = map[string]int{"POST": 1}
+
+	e.receive(reporter.Report{Window: 10 * time.Second, DroppedLate: 1,
+		Sections: []reporter.SectionReport{reportertest.Section("/api", map[string]int{"2xx": 3}), users}})
+	e.receive(reporter.Report{Window: 10 * time.Second, DroppedLate: 2,
+		Sections: []reporter.SectionReport{reportertest.Section("/api", map[string]int{"4xx": 2})}})
+	// rolled up, already counted
+	e.receive(reporter.Report{Window: time.Minute, DroppedLate: 2,
+		Sections: []reporter.SectionReport{reportertest.Section("/api", map[string]int{"2xx": 5})}})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4", w.Header().Get("Content-Type"))
+	out := w.Body.String()
+	for _, line := range []string{
+		"# TYPE monidog_requests_total counter\n",
+		`monidog_requests_total{section="/api"} 5` + "\n",
+		`monidog_requests_total{section="/users"} 1` + "\n",
+		`monidog_responses_total{section="/api",status="2xx"} 3` + "\n",
+		`monidog_responses_total{section="/api",status="4xx"} 2` + "\n",
+		`monidog_requests_by_method_total{section="/users",method="POST"} 1` + "\n",
+		`monidog_response_bytes_total{section="/api"} 500` + "\n",
+		"monidog_late_logs_dropped_total 2\n",
+		"monidog_subscriber_reports_dropped_total 0\n",
+		"monidog_lines_total 7\n",
+		"monidog_parse_errors_total 2\n",
+		"monidog_scanner_offset_bytes 100\n",
+		"monidog_scanner_lag_bytes 50\n",
+		"# TYPE monidog_alert_active gauge\n",
+		`monidog_alert_active{alert="high traffic"} 1` + "\n",
+		`monidog_alert_active{alert="say \"hi\""} 0` + "\n",
+		`monidog_alert_count{alert="high traffic"} 12` + "\n",
+		`monidog_alert_threshold{alert="say \"hi\""} 5` + "\n",
+		"# TYPE monidog_alert_condition_value gauge\n",
+		`monidog_alert_condition_value{alert="api errors",condition="errors"} 3` + "\n",
+	} {
+		assert.Contains(t, out, line)
+	}
+
+	// sections are sorted
+	assert.True(t, bytes.Index(w.Body.Bytes(), []byte(`section="/api"`)) < bytes.Index(w.Body.Bytes(), []byte(`section="/users"`)))
+}
+
+func Test_Exporter_noInput(t *testing.T) {
+	e := New(reporter.NewReporter(10*time.Second), nil)
+	b := &bytes.Buffer{}
+	e.Write(b)
+	assert.Contains(t, b.String(), "# TYPE monidog_requests_total counter\n")
+	assert.NotContains(t, b.String(), "monidog_scanner_lag_bytes")
+	assert.NotContains(t, b.String(), "monidog_alert_active")
+}
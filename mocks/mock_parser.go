@@ -59,6 +59,161 @@ func (mr *MockLogMockRecorder) Resource() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resource", reflect.TypeOf((*MockLog)(nil).Resource))
 }
 
+// MockHTTPLog is a mock of HTTPLog interface
+type MockHTTPLog struct {
+	ctrl     *gomock.Controller
+	recorder *MockHTTPLogMockRecorder
+}
+
+// MockHTTPLogMockRecorder is the mock recorder for MockHTTPLog
+type MockHTTPLogMockRecorder struct {
+	mock *MockHTTPLog
+}
+
+// NewMockHTTPLog creates a new mock instance
+func NewMockHTTPLog(ctrl *gomock.Controller) *MockHTTPLog {
+	mock := &MockHTTPLog{ctrl: ctrl}
+	mock.recorder = &MockHTTPLogMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockHTTPLog) EXPECT() *MockHTTPLogMockRecorder {
+	return m.recorder
+}
+
+// Timestamp mocks base method
+func (m *MockHTTPLog) Timestamp() time.Time {
+	ret := m.ctrl.Call(m, "Timestamp")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// Timestamp indicates an expected call of Timestamp
+func (mr *MockHTTPLogMockRecorder) Timestamp() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Timestamp", reflect.TypeOf((*MockHTTPLog)(nil).Timestamp))
+}
+
+// Resource mocks base method
+func (m *MockHTTPLog) Resource() string {
+	ret := m.ctrl.Call(m, "Resource")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Resource indicates an expected call of Resource
+func (mr *MockHTTPLogMockRecorder) Resource() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resource", reflect.TypeOf((*MockHTTPLog)(nil).Resource))
+}
+
+// Host mocks base method
+func (m *MockHTTPLog) Host() string {
+	ret := m.ctrl.Call(m, "Host")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Host indicates an expected call of Host
+func (mr *MockHTTPLogMockRecorder) Host() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Host", reflect.TypeOf((*MockHTTPLog)(nil).Host))
+}
+
+// User mocks base method
+func (m *MockHTTPLog) User() string {
+	ret := m.ctrl.Call(m, "User")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// User indicates an expected call of User
+func (mr *MockHTTPLogMockRecorder) User() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "User", reflect.TypeOf((*MockHTTPLog)(nil).User))
+}
+
+// Method mocks base method
+func (m *MockHTTPLog) Method() string {
+	ret := m.ctrl.Call(m, "Method")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Method indicates an expected call of Method
+func (mr *MockHTTPLogMockRecorder) Method() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Method", reflect.TypeOf((*MockHTTPLog)(nil).Method))
+}
+
+// Protocol mocks base method
+func (m *MockHTTPLog) Protocol() string {
+	ret := m.ctrl.Call(m, "Protocol")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Protocol indicates an expected call of Protocol
+func (mr *MockHTTPLogMockRecorder) Protocol() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Protocol", reflect.TypeOf((*MockHTTPLog)(nil).Protocol))
+}
+
+// Referer mocks base method
+func (m *MockHTTPLog) Referer() string {
+	ret := m.ctrl.Call(m, "Referer")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Referer indicates an expected call of Referer
+func (mr *MockHTTPLogMockRecorder) Referer() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Referer", reflect.TypeOf((*MockHTTPLog)(nil).Referer))
+}
+
+// UserAgent mocks base method
+func (m *MockHTTPLog) UserAgent() string {
+	ret := m.ctrl.Call(m, "UserAgent")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// UserAgent indicates an expected call of UserAgent
+func (mr *MockHTTPLogMockRecorder) UserAgent() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserAgent", reflect.TypeOf((*MockHTTPLog)(nil).UserAgent))
+}
+
+// Status mocks base method
+func (m *MockHTTPLog) Status() int {
+	ret := m.ctrl.Call(m, "Status")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Status indicates an expected call of Status
+func (mr *MockHTTPLogMockRecorder) Status() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockHTTPLog)(nil).Status))
+}
+
+// Size mocks base method
+func (m *MockHTTPLog) Size() uint64 {
+	ret := m.ctrl.Call(m, "Size")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Size indicates an expected call of Size
+func (mr *MockHTTPLogMockRecorder) Size() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockHTTPLog)(nil).Size))
+}
+
+// VirtualHost mocks base method
+func (m *MockHTTPLog) VirtualHost() string {
+	ret := m.ctrl.Call(m, "VirtualHost")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// VirtualHost indicates an expected call of VirtualHost
+func (mr *MockHTTPLogMockRecorder) VirtualHost() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VirtualHost", reflect.TypeOf((*MockHTTPLog)(nil).VirtualHost))
+}
+
 // MockLogParser is a mock of LogParser interface
 type MockLogParser struct {
 	ctrl     *gomock.Controller
@@ -6,12 +6,20 @@ import "time"
 type Bucket struct {
 	Ts       time.Time
 	counters map[string]int
+	stats    map[string]*Stats
+	tops     map[string]*TopK
+	uniques  map[string]*Uniques
+	total    *Uniques
 }
 
 func NewBucket(ts time.Time) *Bucket {
 	bucket := Bucket{
 		Ts:       ts,
 		counters: map[string]int{},
+		stats:    map[string]*Stats{},
+		tops:     map[string]*TopK{},
+		uniques:  map[string]*Uniques{},
+		total:    NewUniques(TotalHLLPrecision),
 	}
 	return &bucket
 }
@@ -28,6 +36,98 @@ func (b *Bucket) Inc(s string) int {
 	return v
 }
 
+// Add increments a counter and records the breakdown of the hit
+func (b *Bucket) Add(s string, h Hit) int {
+	st, ok := b.stats[s]
+	if !ok {
+		n := NewStats()
+		st = &n
+		b.stats[s] = st
+	}
+	st.Add(h)
+	for top, k := range h.keys() {
+		t, ok := b.tops[top]
+		if !ok {
+			t = NewTopK(DefaultTopKCapacity)
+			b.tops[top] = t
+		}
+		t.Add(k)
+	}
+	u, ok := b.uniques[s]
+	if !ok {
+		u = NewUniques(SectionHLLPrecision)
+		b.uniques[s] = u
+	}
+	u.Add(h)
+	b.total.Add(h)
+	return b.Inc(s)
+}
+
+// Merge adds the hits of o to b, so buckets can be rolled up into coarser ones
+func (b *Bucket) Merge(o *Bucket) {
+	for k, v := range o.counters {
+		b.counters[k] += v
+	}
+	for k, v := range o.stats {
+		if st, ok := b.stats[k]; ok {
+			st.Merge(*v)
+			continue
+		}
+		c := v.Copy()
+		b.stats[k] = &c
+	}
+	for k, v := range o.tops {
+		if t, ok := b.tops[k]; ok {
+			t.Merge(v)
+			continue
+		}
+		b.tops[k] = v.Copy()
+	}
+	for k, v := range o.uniques {
+		if u, ok := b.uniques[k]; ok {
+			u.Merge(v)
+			continue
+		}
+		b.uniques[k] = v.Copy()
+	}
+	b.total.Merge(o.total)
+}
+
+// Uniques returns a copy of the distinct clients and paths sketches of the hits
+// recorded with Add, per section
+func (b *Bucket) Uniques() map[string]*Uniques {
+	m := make(map[string]*Uniques)
+	for k, v := range b.uniques {
+		m[k] = v.Copy()
+	}
+	return m
+}
+
+// TotalUniques returns a copy of the distinct clients and paths sketches of all
+// the hits recorded with Add
+func (b *Bucket) TotalUniques() *Uniques {
+	return b.total.Copy()
+}
+
+// Tops returns a copy of the heavy hitters of the hits recorded with Add, by
+// TopKeys. Their memory is bounded by DefaultTopKCapacity
+func (b *Bucket) Tops() map[string]*TopK {
+	m := make(map[string]*TopK)
+	for k, v := range b.tops {
+		m[k] = v.Copy()
+	}
+	return m
+}
+
+// Stats returns a copy of the breakdown of the hits recorded with Add
+func (b *Bucket) Stats() map[string]Stats {
+	m := make(map[string]Stats)
+	for k, v := range b.stats {
+		m[k] = v.Copy()
+	}
+	return m
+}
+
 // Counters returns a copy of the internal counters map
 func (b *Bucket) Counters() map[string]int {
 	m := make(map[string]int)
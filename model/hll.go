@@ -0,0 +1,160 @@
+package model
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Precisions of the HyperLogLog sketches kept in buckets. A sketch of precision p
+// takes 2^p bytes and has a standard error of 1.04/sqrt(2^p): 1.6% for window
+// totals and 3.3% per section
+const (
+	TotalHLLPrecision   = 12
+	SectionHLLPrecision = 10
+)
+
+// HLL estimates the number of distinct keys added to it with HyperLogLog, in
+// constant memory. Sketches of the same precision merge losslessly, so the
+// sketch of a window is the merge of the sketches of its buckets
+type HLL struct {
+	p         uint8
+	registers []uint8
+}
+
+// NewHLL constructs an empty sketch of precision p, between 4 and 16
+func NewHLL(p uint8) *HLL {
+	if p < 4 {
+		p = 4
+	}
+	if p > 16 {
+		p = 16
+	}
+	return &HLL{p: p, registers: make([]uint8, 1<<p)}
+}
+
+// hash64 hashes a key with FNV-1a followed by a finalizer that spreads its bits,
+// which HyperLogLog needs to be uniform
+func hash64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Add counts key
+func (h *HLL) Add(key string) {
+	x := hash64(key)
+	i := x >> (64 - h.p)
+	// rank of the first set bit of the remaining bits
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// Merge adds the keys of o to h. Sketches of different precisions are merged
+// at the lower one
+func (h *HLL) Merge(o *HLL) {
+	if o.p < h.p {
+		h.fold(o.p)
+	}
+	shift := o.p - h.p
+	for i, r := range o.registers {
+		if r == 0 {
+			continue
+		}
+		j := i >> shift
+		if shift > 0 {
+			// the index bits dropped become leading bits of the rest
+			rest := uint64(i) & (1<<shift - 1)
+			if rest != 0 {
+				r = uint8(bits.LeadingZeros64(rest<<(64-shift))) + 1
+			} else {
+				r += shift
+			}
+		}
+		if r > h.registers[j] {
+			h.registers[j] = r
+		}
+	}
+}
+
+// fold lowers the precision of h to p
+func (h *HLL) fold(p uint8) {
+	folded := NewHLL(p)
+	folded.Merge(h)
+	*h = *folded
+}
+
+// Copy returns a copy of h
+func (h *HLL) Copy() *HLL {
+	c := &HLL{p: h.p, registers: make([]uint8, len(h.registers))}
+	copy(c.registers, h.registers)
+	return c
+}
+
+// Estimate returns the estimated number of distinct keys
+func (h *HLL) Estimate() uint64 {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	switch m {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	}
+	e := alpha * m * m / sum
+	// small range correction
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
+
+// Uniques estimates the distinct clients and request paths of some hits
+type Uniques struct {
+	Clients *HLL
+	URLs    *HLL
+}
+
+// NewUniques constructs empty sketches of precision p
+func NewUniques(p uint8) *Uniques {
+	return &Uniques{Clients: NewHLL(p), URLs: NewHLL(p)}
+}
+
+// Add counts the client and path of a hit, if known
+func (u *Uniques) Add(h Hit) {
+	if h.Client != "" && h.Client != "-" {
+		u.Clients.Add(h.Client)
+	}
+	if h.Path != "" {
+		u.URLs.Add(h.Path)
+	}
+}
+
+// Merge adds the clients and paths of o to u
+func (u *Uniques) Merge(o *Uniques) {
+	u.Clients.Merge(o.Clients)
+	u.URLs.Merge(o.URLs)
+}
+
+// Copy returns a copy of u
+func (u *Uniques) Copy() *Uniques {
+	return &Uniques{Clients: u.Clients.Copy(), URLs: u.URLs.Copy()}
+}
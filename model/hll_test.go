@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// within checks an estimate is within 4 standard errors of n
+func within(t *testing.T, n int, h *HLL) {
+	stderr := 1.04 / math.Sqrt(float64(len(h.registers)))
+	e := float64(h.Estimate())
+	assert.True(t, math.Abs(e-float64(n)) <= 4*stderr*float64(n)+1, "estimate %v of %d", e, n)
+}
+
+func Test_HLL(t *testing.T) {
+	h := NewHLL(12)
+	assert.Equal(t, uint64(0), h.Estimate())
+	for _, n := range []int{10, 1000, 100000} {
+		h := NewHLL(12)
+		for i := 0; i < n; i++ {
+			// duplicates don't count
+			h.Add(fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&255, i&255))
+			h.Add(fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&255, i&255))
+		}
+		within(t, n, h)
+	}
+	assert.Len(t, NewHLL(20).registers, 1<<16)
+	assert.Len(t, NewHLL(1).registers, 1<<4)
+}
+
+func Test_HLL_Merge(t *testing.T) {
+	a, b := NewHLL(12), NewHLL(12)
+	for i := 0; i < 30000; i++ {
+		a.Add(fmt.Sprint("/pages/", i))
+	}
+	for i := 20000; i < 50000; i++ {
+		b.Add(fmt.Sprint("/pages/", i))
+	}
+	c := a.Copy()
+	c.Merge(b)
+	within(t, 50000, c)
+	within(t, 30000, a)
+
+	// merging sketches of different precisions folds to the lower one
+	lo := NewHLL(10)
+	lo.Merge(c)
+	assert.Len(t, lo.registers, 1<<10)
+	within(t, 50000, lo)
+	hi := c.Copy()
+	hi.Merge(NewHLL(8))
+	assert.Len(t, hi.registers, 1<<8)
+	within(t, 50000, hi)
+
+	// folding gives the same sketch as adding at the lower precision
+	direct := NewHLL(10)
+	for i := 0; i < 50000; i++ {
+		direct.Add(fmt.Sprint("/pages/", i))
+	}
+	assert.Equal(t, direct.registers, lo.registers)
+}
+
+func Test_Uniques(t *testing.T) {
+	u := NewUniques(SectionHLLPrecision)
+	u.Add(Hit{Client: "10.0.0.1", Path: "/a"})
+	u.Add(Hit{Client: "10.0.0.1", Path: "/b"})
+	u.Add(Hit{Client: "-"})
+	o := NewUniques(SectionHLLPrecision)
+	o.Add(Hit{Client: "10.0.0.2", Path: "/a"})
+	u.Merge(o)
+	assert.Equal(t, uint64(2), u.Clients.Estimate())
+	assert.Equal(t, uint64(2), u.URLs.Estimate())
+}
@@ -0,0 +1,114 @@
+package model
+
+import "strconv"
+
+// Hit describes a single request counted in a bucket. Zero fields are unknown
+type Hit struct {
+	Status    int
+	Method    string
+	Bytes     uint64
+	Client    string
+	UserAgent string
+	Referrer  string
+	Path      string
+}
+
+// Heavy hitters tracked for the hits of a bucket
+const (
+	TopClients    = "clients"
+	TopUserAgents = "user agents"
+	TopReferrers  = "referrers"
+	TopPaths      = "paths"
+)
+
+// TopKeys lists the heavy hitters in the order they are reported
+var TopKeys = []string{TopClients, TopUserAgents, TopReferrers, TopPaths}
+
+// keys returns the heavy hitter keys of a hit. Unknown values, logged as - or
+// empty, are left out
+func (h Hit) keys() map[string]string {
+	keys := map[string]string{}
+	for top, v := range map[string]string{
+		TopClients:    h.Client,
+		TopUserAgents: h.UserAgent,
+		TopReferrers:  h.Referrer,
+		TopPaths:      h.Path,
+	} {
+		if v != "" && v != "-" {
+			keys[top] = v
+		}
+	}
+	return keys
+}
+
+// StatusClass returns the class of an HTTP status, e.g. 2xx. Unknown statuses
+// have no class
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return ""
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// Stats is the breakdown of a set of hits
+type Stats struct {
+	Hits     int            `json:"hits"`
+	Statuses map[string]int `json:"statuses"`
+	Methods  map[string]int `json:"methods"`
+	Bytes    uint64         `json:"bytes"`
+}
+
+// NewStats constructs empty stats
+func NewStats() Stats {
+	return Stats{
+		Statuses: map[string]int{},
+		Methods:  map[string]int{},
+	}
+}
+
+// Add counts a hit
+func (s *Stats) Add(h Hit) {
+	s.Hits++
+	if c := StatusClass(h.Status); c != "" {
+		s.Statuses[c]++
+	}
+	if h.Method != "" {
+		s.Methods[h.Method]++
+	}
+	s.Bytes += h.Bytes
+}
+
+// Merge adds the hits of o to s
+func (s *Stats) Merge(o Stats) {
+	s.Hits += o.Hits
+	for k, v := range o.Statuses {
+		s.Statuses[k] += v
+	}
+	for k, v := range o.Methods {
+		s.Methods[k] += v
+	}
+	s.Bytes += o.Bytes
+}
+
+// Copy returns a deep copy of s
+func (s Stats) Copy() Stats {
+	c := NewStats()
+	c.Merge(s)
+	return c
+}
+
+// AvgBytes is the average response size
+func (s Stats) AvgBytes() float64 {
+	if s.Hits == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.Hits)
+}
+
+// ErrorPercent is the percentage of hits that got a 4xx or 5xx response
+func (s Stats) ErrorPercent() float64 {
+	if s.Hits == 0 {
+		return 0
+	}
+	return float64(s.Statuses["4xx"]+s.Statuses["5xx"]) * 100 / float64(s.Hits)
+}
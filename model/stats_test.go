@@ -0,0 +1,71 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StatusClass(t *testing.T) {
+	assert.Equal(t, "2xx", StatusClass(200))
+	assert.Equal(t, "3xx", StatusClass(304))
+	assert.Equal(t, "5xx", StatusClass(599))
+	assert.Equal(t, "", StatusClass(0))
+	assert.Equal(t, "", StatusClass(600))
+}
+
+func Test_Stats(t *testing.T) {
+	s := NewStats()
+	assert.Equal(t, 0.0, s.AvgBytes())
+	assert.Equal(t, 0.0, s.ErrorPercent())
+
+	s.Add(Hit{Status: 200, Method: "GET", Bytes: 100})
+	s.Add(Hit{Status: 404, Method: "GET", Bytes: 300})
+	o := NewStats()
+	o.Add(Hit{Status: 500, Method: "POST"})
+	o.Add(Hit{})
+	s.Merge(o)
+
+	assert.Equal(t, 4, s.Hits)
+	assert.Equal(t, map[string]int{"2xx": 1, "4xx": 1, "5xx": 1}, s.Statuses)
+	assert.Equal(t, map[string]int{"GET": 2, "POST": 1}, s.Methods)
+	assert.Equal(t, uint64(400), s.Bytes)
+	assert.Equal(t, 100.0, s.AvgBytes())
+	assert.Equal(t, 50.0, s.ErrorPercent())
+
+	c := s.Copy()
+	c.Add(Hit{Status: 200})
+	assert.Equal(t, 1, s.Statuses["2xx"])
+}
+
+func Test_Bucket_Add(t *testing.T) {
+	b := NewBucket(time.Now())
+	assert.Equal(t, 1, b.Add("/a", Hit{Status: 200, Bytes: 10}))
+	assert.Equal(t, 2, b.Add("/a", Hit{Status: 503, Bytes: 30}))
+	b.Inc("/b")
+
+	assert.Equal(t, map[string]int{"/a": 2, "/b": 1}, b.Counters())
+	stats := b.Stats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, 2, stats["/a"].Hits)
+	assert.Equal(t, 20.0, stats["/a"].AvgBytes())
+}
+
+func Test_Bucket_Merge(t *testing.T) {
+	a, b := NewBucket(time.Now()), NewBucket(time.Now())
+	a.Add("/a", Hit{Status: 200, Client: "10.0.0.1", Path: "/a/1"})
+	b.Add("/a", Hit{Status: 404, Client: "10.0.0.2", Path: "/a/1"})
+	b.Add("/b", Hit{Status: 200, Client: "10.0.0.1", Path: "/b/1"})
+	a.Merge(b)
+
+	assert.Equal(t, map[string]int{"/a": 2, "/b": 1}, a.Counters())
+	assert.Equal(t, 50.0, a.Stats()["/a"].ErrorPercent())
+	assert.Equal(t, []KeyCount{{"10.0.0.1", 2, 0}, {"10.0.0.2", 1, 0}}, a.Tops()[TopClients].Top(-1))
+	assert.Equal(t, uint64(2), a.Uniques()["/a"].Clients.Estimate())
+	assert.Equal(t, uint64(1), a.Uniques()["/a"].URLs.Estimate())
+	assert.Equal(t, uint64(2), a.TotalUniques().URLs.Estimate())
+
+	// b is left untouched
+	assert.Equal(t, map[string]int{"/a": 1, "/b": 1}, b.Counters())
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// DefaultTopKCapacity is the number of keys a TopK tracks by default
+const DefaultTopKCapacity = 100
+
+// KeyCount is the estimated count of a key. The true count is between
+// Count-Err and Count
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Err   int    `json:"err"`
+}
+
+// TopK finds the most frequent keys of a stream in bounded memory with the
+// Space-Saving algorithm. It tracks at most capacity keys; when a new key comes
+// in and it is full, the key with the lowest count is replaced and the new key
+// inherits its count as error. Any key seen more than total/capacity times is
+// guaranteed to be tracked
+type TopK struct {
+	capacity int
+	keys     map[string]*KeyCount
+	heap     countHeap
+}
+
+// NewTopK constructs a TopK tracking at most capacity keys
+func NewTopK(capacity int) *TopK {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &TopK{
+		capacity: capacity,
+		keys:     map[string]*KeyCount{},
+	}
+}
+
+// Add counts one occurrence of key
+func (t *TopK) Add(key string) {
+	t.add(key, 1, 0)
+}
+
+func (t *TopK) add(key string, count, err int) {
+	if c, ok := t.keys[key]; ok {
+		c.Count += count
+		c.Err += err
+		heap.Fix(&t.heap, t.heap.index(c))
+		return
+	}
+	if len(t.keys) < t.capacity {
+		c := &KeyCount{Key: key, Count: count, Err: err}
+		t.keys[key] = c
+		heap.Push(&t.heap, c)
+		return
+	}
+	// replace the key with the lowest count
+	min := t.heap.items[0]
+	delete(t.keys, min.Key)
+	min.Key, min.Err, min.Count = key, min.Count+err, min.Count+count
+	t.keys[key] = min
+	heap.Fix(&t.heap, 0)
+}
+
+// min is the count a key that is not tracked may have at most
+func (t *TopK) min() int {
+	if len(t.keys) < t.capacity {
+		return 0
+	}
+	return t.heap.items[0].Count
+}
+
+// Merge adds the counts of o to t. Keys tracked by only one of them get the
+// minimum count of the other added to their count and error
+func (t *TopK) Merge(o *TopK) {
+	tmin, omin := t.min(), o.min()
+	merged := make(map[string]KeyCount, len(t.keys)+len(o.keys))
+	for k, c := range t.keys {
+		m := *c
+		if oc, ok := o.keys[k]; ok {
+			m.Count += oc.Count
+			m.Err += oc.Err
+		} else {
+			m.Count += omin
+			m.Err += omin
+		}
+		merged[k] = m
+	}
+	for k, oc := range o.keys {
+		if _, ok := t.keys[k]; ok {
+			continue
+		}
+		merged[k] = KeyCount{Key: k, Count: oc.Count + tmin, Err: oc.Err + tmin}
+	}
+
+	all := make([]KeyCount, 0, len(merged))
+	for _, c := range merged {
+		all = append(all, c)
+	}
+	sortCounts(all)
+	if len(all) > t.capacity {
+		all = all[:t.capacity]
+	}
+	t.keys = make(map[string]*KeyCount, len(all))
+	t.heap = countHeap{}
+	for i := range all {
+		c := all[i]
+		t.keys[c.Key] = &c
+		heap.Push(&t.heap, &c)
+	}
+}
+
+// Copy returns a copy of t
+func (t *TopK) Copy() *TopK {
+	c := NewTopK(t.capacity)
+	c.Merge(t)
+	return c
+}
+
+// Top returns the n keys with the highest counts, highest first. Ties are
+// ordered by key
+func (t *TopK) Top(n int) []KeyCount {
+	all := make([]KeyCount, 0, len(t.keys))
+	for _, c := range t.keys {
+		all = append(all, *c)
+	}
+	sortCounts(all)
+	if n >= 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+func sortCounts(counts []KeyCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Key < counts[j].Key
+	})
+}
+
+// countHeap is a min heap of counts that remembers where each count is
+type countHeap struct {
+	items []*KeyCount
+	pos   map[*KeyCount]int
+}
+
+func (h *countHeap) index(c *KeyCount) int { return h.pos[c] }
+func (h countHeap) Len() int               { return len(h.items) }
+func (h countHeap) Less(i, j int) bool     { return h.items[i].Count < h.items[j].Count }
+
+func (h countHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.pos[h.items[i]] = i
+	h.pos[h.items[j]] = j
+}
+
+func (h *countHeap) Push(x interface{}) {
+	if h.pos == nil {
+		h.pos = map[*KeyCount]int{}
+	}
+	c := x.(*KeyCount)
+	h.pos[c] = len(h.items)
+	h.items = append(h.items, c)
+}
+
+func (h *countHeap) Pop() interface{} {
+	c := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.pos, c)
+	return c
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TopK(t *testing.T) {
+	k := NewTopK(3)
+	for _, key := range []string{"a", "b", "a", "c", "a", "b"} {
+		k.Add(key)
+	}
+	assert.Equal(t, []KeyCount{{"a", 3, 0}, {"b", 2, 0}, {"c", 1, 0}}, k.Top(-1))
+
+	// d replaces c, the lowest, and inherits its count as error
+	k.Add("d")
+	assert.Equal(t, []KeyCount{{"a", 3, 0}, {"b", 2, 0}, {"d", 2, 1}}, k.Top(5))
+	assert.Equal(t, []KeyCount{{"a", 3, 0}}, k.Top(1))
+}
+
+// Test_TopK_heavyHitters checks the Space-Saving guarantees against exact counts
+// on a skewed stream: counts never underestimate, the error is bounded and every
+// key above total/capacity is tracked
+func Test_TopK_heavyHitters(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.2, 1, 10000)
+	capacity, total := 50, 20000
+	k := NewTopK(capacity)
+	exact := map[string]int{}
+	for i := 0; i < total; i++ {
+		key := fmt.Sprintf("10.0.0.%d", z.Uint64())
+		k.Add(key)
+		exact[key]++
+	}
+
+	tracked := map[string]KeyCount{}
+	for _, c := range k.Top(-1) {
+		tracked[c.Key] = c
+		assert.True(t, c.Count >= exact[c.Key], c.Key)
+		assert.True(t, c.Count-c.Err <= exact[c.Key], c.Key)
+		assert.True(t, c.Err <= total/capacity, c.Key)
+	}
+	assert.Len(t, tracked, capacity)
+	for key, n := range exact {
+		if n > total/capacity {
+			_, ok := tracked[key]
+			assert.True(t, ok, key)
+		}
+	}
+	assert.Equal(t, "10.0.0.0", k.Top(1)[0].Key)
+}
+
+func Test_TopK_Merge(t *testing.T) {
+	a, b := NewTopK(2), NewTopK(2)
+	for _, key := range []string{"x", "x", "x", "y"} {
+		a.Add(key)
+	}
+	for _, key := range []string{"x", "z", "z"} {
+		b.Add(key)
+	}
+	a.Merge(b)
+	// y is missing from b, which is full, so it may have had up to b's min count
+	assert.Equal(t, []KeyCount{{"x", 4, 0}, {"z", 3, 1}}, a.Top(-1))
+
+	c := a.Copy()
+	c.Add("z")
+	c.Add("z")
+	assert.Equal(t, "z", c.Top(1)[0].Key)
+	assert.Equal(t, "x", a.Top(1)[0].Key)
+
+	// merging into an empty one keeps the counts
+	e := NewTopK(10)
+	e.Merge(a)
+	assert.Equal(t, a.Top(-1), e.Top(-1))
+}
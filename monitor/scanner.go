@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/mihaichiorean/monidog/parser"
@@ -25,6 +26,32 @@ type LogScanner interface {
 	Close() error
 }
 
+// ProgressReporter is implemented by scanners that tell how far they got through
+// their input, like the ones returned by Watch
+type ProgressReporter interface {
+	Stats() Stats
+}
+
+// Stats is how far a scanner got through its file
+type Stats struct {
+	// Offset is the position in the file after the last line read
+	Offset int64
+	// Size is the current size of the file
+	Size int64
+	// Lines is how many lines were parsed, ParseErrors how many failed to
+	Lines       int
+	ParseErrors int
+}
+
+// Lag is how many bytes were written to the file that were not read yet
+func (s Stats) Lag() int64 {
+	if s.Size < s.Offset {
+		// truncated, the scanner starts over on its next check
+		return s.Size
+	}
+	return s.Size - s.Offset
+}
+
 // Watch will start watching a file, scan and parse new logs
 func Watch(f SeekReader, p parser.LogParser, every time.Duration, lo *zap.Logger) (LogScanner, error) {
 	log := lo.Sugar()
@@ -38,6 +65,7 @@ func Watch(f SeekReader, p parser.LogParser, every time.Duration, lo *zap.Logger
 		subscribing:   make(chan chan parser.Log),
 		closing:       make(chan chan error),
 		parser:        p,
+		file:          f,
 	}
 	go ls.loop(f)
 	return &ls, nil
@@ -50,6 +78,10 @@ type logScanner struct {
 	parser      parser.LogParser
 	subscribing chan chan parser.Log
 	closing     chan chan error
+
+	file  SeekReader
+	mu    sync.Mutex
+	stats Stats
 }
 
 // Subscribe creates a new channel for the client caller and passes that to the worker
@@ -60,6 +92,18 @@ func (ls *logScanner) Subscribe() <-chan parser.Log {
 	return ch
 }
 
+// Stats returns how far the scanner got through its file. It is safe to call
+// while the scanner runs
+func (ls *logScanner) Stats() Stats {
+	ls.mu.Lock()
+	s := ls.stats
+	ls.mu.Unlock()
+	if fi, err := ls.file.Stat(); err == nil {
+		s.Size = fi.Size()
+	}
+	return s
+}
+
 func (ls *logScanner) Close() error {
 	errc := make(chan error)
 	ls.closing <- errc
@@ -82,12 +126,20 @@ func (ls *logScanner) readLines(f io.Reader) ([]parser.Log, error) {
 		// (IMPROVEMENT) could probably implement a scanner that returns a log struct instead of string
 		t := scanner.Text()
 		l, err := ls.parseLog(t)
+		ls.mu.Lock()
+		if err != nil {
+			ls.stats.ParseErrors++
+		} else {
+			ls.stats.Lines++
+		}
+		ls.mu.Unlock()
 		if err != nil {
 			ls.With(
 				zap.Error(err),
 				zap.String("line", t),
 			).Debug("Failed to parse log line")
-			break
+			// skip it, the lines after it were read already and would be lost
+			continue
 		}
 		newLogs = append(newLogs, l)
 	}
@@ -184,10 +236,14 @@ func (ls *logScanner) loop(f SeekReader) {
 			}
 
 			// read fresh content
-			logLines, err := ls.readLines(f)
+			r := &countingReader{Reader: f}
+			logLines, err := ls.readLines(r)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, err.Error())
+				fmt.Fprintln(os.Stderr, err.Error())
 			}
+			ls.mu.Lock()
+			ls.stats.Offset = pos + r.n
+			ls.mu.Unlock()
 			if len(logLines) > 0 {
 				queue = append(queue, logLines...)
 			}
@@ -208,3 +264,15 @@ func (ls *logScanner) loop(f SeekReader) {
 		}
 	}
 }
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n += int64(n)
+	return n, err
+}
@@ -3,6 +3,9 @@ package monitor
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,6 +34,10 @@ func Test_Watch_OK(t *testing.T) {
 	mockSeekReader := mocks.NewMockSeekReader(mockCtrl)
 	fi := mocks.NewMockFileInfo(mockCtrl)
 	mockSeekReader.EXPECT().Stat().Return(fi, nil)
+	// the file may be checked before Close
+	mockSeekReader.EXPECT().Seek(int64(0), io.SeekCurrent).Return(int64(0), nil).AnyTimes()
+	mockSeekReader.EXPECT().Stat().Return(fi, nil).AnyTimes()
+	fi.EXPECT().Size().Return(int64(0)).AnyTimes()
 	logger := zap.NewNop()
 	scanner, err := Watch(mockSeekReader, p, 1*time.Millisecond, logger)
 	assert.NoError(t, err)
@@ -166,3 +173,56 @@ func Test_loop(t *testing.T) {
 	assert.NoError(t, ls.Close())
 	assert.NotNil(t, l)
 }
+
+func Test_readLines(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	p := mocks.NewMockLogParser(mockCtrl)
+	first := mocks.NewMockLog(mockCtrl)
+	last := mocks.NewMockLog(mockCtrl)
+	p.EXPECT().Parse("first").Return(first, nil)
+	p.EXPECT().Parse("bad").Return(nil, fmt.Errorf("bad line"))
+	p.EXPECT().Parse("last").Return(last, nil)
+	ls := logScanner{
+		SugaredLogger: zap.NewNop().Sugar(),
+		parser:        p,
+	}
+
+	logs, err := ls.readLines(strings.NewReader("first\nbad\nlast\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []parser.Log{first, last}, logs)
+}
+
+func Test_Stats(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	f, err := ioutil.TempFile("", "access.log")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	p := mocks.NewMockLogParser(mockCtrl)
+	p.EXPECT().Parse("good").Return(mocks.NewMockLog(mockCtrl), nil).Times(2)
+	p.EXPECT().Parse("bad").Return(nil, fmt.Errorf("bad line"))
+
+	r, err := os.Open(f.Name())
+	assert.NoError(t, err)
+	defer r.Close()
+	ls, err := Watch(r, p, time.Millisecond, zap.NewNop())
+	assert.NoError(t, err)
+	ch := ls.Subscribe()
+	stats := ls.(ProgressReporter)
+	assert.Equal(t, Stats{}, stats.Stats())
+
+	_, err = f.WriteString("good\nbad\ngood\n")
+	assert.NoError(t, err)
+	<-ch
+	<-ch
+	assert.Equal(t, Stats{Offset: 14, Size: 14, Lines: 2, ParseErrors: 1}, stats.Stats())
+	assert.Equal(t, int64(0), stats.Stats().Lag())
+	// truncated
+	assert.Equal(t, int64(4), Stats{Offset: 10, Size: 4}.Lag())
+	assert.NoError(t, ls.Close())
+}
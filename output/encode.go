@@ -0,0 +1,64 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/mihaichiorean/monidog/format"
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/pkg/errors"
+)
+
+// logLine is the JSON object of a log. Fields the log doesn't have are left out;
+// those beyond HTTPLog, e.g. the request time, are under "fields"
+type logLine struct {
+	Type        string    `json:"type"`
+	Timestamp   time.Time `json:"timestamp"`
+	Resource    string    `json:"resource"`
+	Host        string    `json:"host,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	Status      int       `json:"status,omitempty"`
+	Size        uint64    `json:"size,omitempty"`
+	Referer     string    `json:"referer,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	VirtualHost string    `json:"vhost,omitempty"`
+
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Encode encodes an event as a JSON object. Reports are encoded like the json
+// output format does; logs have "type": "log"
+func Encode(e Event) ([]byte, error) {
+	if e.Report != nil {
+		b := &bytes.Buffer{}
+		if err := format.NewWriter(b, format.JSON).Report(*e.Report); err != nil {
+			return nil, err
+		}
+		return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+	}
+	line := logLine{
+		Type:      "log",
+		Timestamp: e.Log.Timestamp(),
+		Resource:  e.Log.Resource(),
+		Fields:    parser.Fields(e.Log),
+	}
+	if h, ok := e.Log.(parser.HTTPLog); ok {
+		line.Host = h.Host()
+		line.User = h.User()
+		line.Method = h.Method()
+		line.Protocol = h.Protocol()
+		line.Status = h.Status()
+		line.Size = h.Size()
+		line.Referer = h.Referer()
+		line.UserAgent = h.UserAgent()
+		line.VirtualHost = h.VirtualHost()
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode log")
+	}
+	return b, nil
+}
@@ -0,0 +1,116 @@
+package output
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Defaults of file sinks
+const (
+	DefaultMaxFileSize = 100 << 20
+	DefaultBackups     = 3
+)
+
+// FileSink appends events to a local file as JSON lines. When the file would
+// grow past its maximum size it is rotated: path becomes path.1, path.1 becomes
+// path.2 and so on, keeping a number of backups
+type FileSink struct {
+	path    string
+	maxSize int64
+	backups int
+
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens a file sink at path, appending to the file if it exists
+func NewFileSink(path string, maxSize int64, backups int) (*FileSink, error) {
+	s := FileSink{
+		path:    path,
+		maxSize: maxSize,
+		backups: backups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", s.path)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to stat %s", s.path)
+	}
+	s.f, s.size = f, fi.Size()
+	return nil
+}
+
+// Write appends an event, rotating the file first if it would get too big. The
+// event is still written when the file could not be rotated, and the rotation
+// error returned after
+func (s *FileSink) Write(e Event) error {
+	b, err := Encode(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	var rotateErr error
+	if s.f != nil && s.size > 0 && s.size+int64(len(b)) > s.maxSize {
+		rotateErr = s.rotate()
+	}
+	if s.f == nil {
+		// closed by a rotation that could not open the file again
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write to %s", s.path)
+	}
+	return rotateErr
+}
+
+// rotate shifts the backups and starts a new file. The file is left closed and
+// nil if it could not be opened again
+func (s *FileSink) rotate() error {
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return errors.Wrapf(err, "failed to close %s", s.path)
+	}
+	for i := s.backups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if s.backups > 0 {
+		err = os.Rename(s.path, s.path+".1")
+	} else {
+		err = os.Remove(s.path)
+	}
+	// keep writing to the same file if it could not be moved
+	if err := s.open(); err != nil {
+		return err
+	}
+	return errors.Wrapf(err, "failed to rotate %s", s.path)
+}
+
+// Flush does nothing, writes go straight to the file
+func (s *FileSink) Flush() error {
+	return nil
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,97 @@
+package output
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "monidog")
+	assert.NoError(t, err)
+	return dir
+}
+
+func cleanup(dir string) {
+	os.RemoveAll(dir)
+}
+
+func lines(t *testing.T, path string) []string {
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func Test_FileSink(t *testing.T) {
+	dir := tempDir(t)
+	defer cleanup(dir)
+	path := filepath.Join(dir, "out.log")
+
+	rep := Event{Report: &reporter.Report{Start: ts, End: ts.Add(10 * time.Second), Window: 10 * time.Second}}
+	b, _ := Encode(rep)
+	// room for two events per file
+	s, err := NewFileSink(path, int64(2*(len(b)+1)), 2)
+	assert.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		assert.NoError(t, s.Write(rep))
+	}
+	assert.NoError(t, s.Flush())
+	assert.NoError(t, s.Close())
+
+	assert.Len(t, lines(t, path), 1)
+	assert.Len(t, lines(t, path+".1"), 2)
+	assert.Len(t, lines(t, path+".2"), 2)
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, string(b), lines(t, path)[0])
+
+	// appends to an existing file
+	s, err = NewFileSink(path, 1<<20, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write(rep))
+	assert.NoError(t, s.Close())
+	assert.Len(t, lines(t, path), 2)
+
+	_, err = NewFileSink(filepath.Join(dir, "missing", "out.log"), 1<<20, 0)
+	assert.Error(t, err)
+}
+
+func Test_FileSink_noBackups(t *testing.T) {
+	dir := tempDir(t)
+	defer cleanup(dir)
+	path := filepath.Join(dir, "out.log")
+
+	rep := Event{Report: &reporter.Report{End: ts}}
+	s, err := NewFileSink(path, 1, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write(rep))
+	assert.NoError(t, s.Write(rep))
+	assert.NoError(t, s.Close())
+	assert.Len(t, lines(t, path), 1)
+	_, err = os.Stat(path + ".1")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_FileSink_rotateFails(t *testing.T) {
+	dir := tempDir(t)
+	defer cleanup(dir)
+	path := filepath.Join(dir, "out.log")
+	// the file can't be moved to path.1
+	assert.NoError(t, os.MkdirAll(filepath.Join(path+".1", "taken"), 0755))
+
+	rep := Event{Report: &reporter.Report{End: ts}}
+	s, err := NewFileSink(path, 1, 1)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write(rep))
+	assert.Error(t, s.Write(rep))
+	assert.Error(t, s.Write(rep))
+	assert.NoError(t, s.Close())
+	// the writes landed in the same file
+	assert.Len(t, lines(t, path), 3)
+}
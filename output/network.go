@@ -0,0 +1,158 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Defaults of the batching network sinks
+const (
+	DefaultBatchSize = 100
+	DefaultRetries   = 3
+	DefaultBackoff   = 100 * time.Millisecond
+)
+
+// batcher buffers encoded events and sends them in batches, when a batch is
+// full or on flush. Failed sends are retried with exponential backoff, then the
+// batch is dropped so a dead endpoint doesn't grow it forever
+type batcher struct {
+	size    int
+	retries int
+	backoff time.Duration
+	lines   [][]byte
+	send    func(batch []byte) error
+}
+
+func newBatcher(send func(batch []byte) error) batcher {
+	return batcher{
+		size:    DefaultBatchSize,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+		send:    send,
+	}
+}
+
+// SetBatchSize sets how many events are sent together
+func (b *batcher) SetBatchSize(n int) {
+	b.size = n
+}
+
+// SetRetries sets how many times a failed batch is sent again, waiting backoff,
+// then twice as long and so on between attempts
+func (b *batcher) SetRetries(n int, backoff time.Duration) {
+	b.retries, b.backoff = n, backoff
+}
+
+// Write encodes an event and sends the batch if it is full
+func (b *batcher) Write(e Event) error {
+	line, err := Encode(e)
+	if err != nil {
+		return err
+	}
+	b.lines = append(b.lines, line)
+	if len(b.lines) >= b.size {
+		return b.Flush()
+	}
+	return nil
+}
+
+// Flush sends the buffered events as JSON lines
+func (b *batcher) Flush() error {
+	if len(b.lines) == 0 {
+		return nil
+	}
+	batch := append(bytes.Join(b.lines, []byte("\n")), '\n')
+	n := len(b.lines)
+	b.lines = nil
+	var err error
+	for i := 0; i <= b.retries; i++ {
+		if i > 0 {
+			time.Sleep(b.backoff << uint(i-1))
+		}
+		if err = b.send(batch); err == nil {
+			return nil
+		}
+	}
+	return errors.Wrapf(err, "dropped %d events after %d attempts", n, b.retries+1)
+}
+
+// TCPSink sends batches of events as JSON lines over a TCP connection
+type TCPSink struct {
+	batcher
+	addr string
+	conn net.Conn
+}
+
+// NewTCPSink constructs a sink to a TCP address. It connects when the first
+// batch is sent and reconnects after errors
+func NewTCPSink(addr string) *TCPSink {
+	s := TCPSink{addr: addr}
+	s.batcher = newBatcher(s.sendBatch)
+	return &s
+}
+
+func (s *TCPSink) sendBatch(batch []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.addr, networkTimeout)
+		if err != nil {
+			return errors.Wrapf(err, "failed to connect to %s", s.addr)
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(networkTimeout))
+	if _, err := s.conn.Write(batch); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return errors.Wrapf(err, "failed to send to %s", s.addr)
+	}
+	return nil
+}
+
+// Close sends the buffered events and closes the connection
+func (s *TCPSink) Close() error {
+	err := s.Flush()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// HTTPSink posts batches of events as JSON lines to a URL
+type HTTPSink struct {
+	batcher
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink constructs a sink posting to url
+func NewHTTPSink(url string) *HTTPSink {
+	s := HTTPSink{
+		url:    url,
+		client: &http.Client{Timeout: 2 * networkTimeout},
+	}
+	s.batcher = newBatcher(s.sendBatch)
+	return &s
+}
+
+func (s *HTTPSink) sendBatch(batch []byte) error {
+	resp, err := s.client.Post(s.url, "application/x-ndjson", bytes.NewReader(batch))
+	if err != nil {
+		return errors.Wrapf(err, "failed to post to %s", s.url)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post to %s: %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// Close sends the buffered events
+func (s *HTTPSink) Close() error {
+	return s.Flush()
+}
@@ -0,0 +1,126 @@
+package output
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TCPSink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	received := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			received <- scanner.Text()
+		}
+	}()
+
+	s := NewTCPSink(ln.Addr().String())
+	s.SetBatchSize(2)
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/a", 200)}))
+	select {
+	case <-received:
+		t.Fatal("sent before the batch was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/b", 200)}))
+	assert.Contains(t, <-received, `"resource":"/a"`)
+	assert.Contains(t, <-received, `"resource":"/b"`)
+
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/c", 200)}))
+	assert.NoError(t, s.Close())
+	assert.Contains(t, <-received, `"resource":"/c"`)
+}
+
+func Test_TCPSink_unreachable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := NewTCPSink(addr)
+	s.SetRetries(2, time.Millisecond)
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/a", 200)}))
+	err = s.Flush()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dropped 1 events after 3 attempts")
+	// the batch was dropped
+	assert.NoError(t, s.Flush())
+}
+
+func Test_HTTPSink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	attempts := 0
+	bodies := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		// the first attempt fails
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(srv.URL)
+	s.SetRetries(1, time.Millisecond)
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/a", 200)}))
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/b", 200)}))
+	assert.NoError(t, s.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, attempts)
+	assert.Len(t, bodies, 1)
+	lines := strings.Split(strings.TrimSuffix(bodies[0], "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"resource":"/b"`)
+}
+
+func Test_HTTPSink_failing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(srv.URL)
+	s.SetBatchSize(1)
+	s.SetRetries(0, 0)
+	err := s.Write(Event{Log: httpLog(ctrl, "/a", 200)})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "500")
+}
@@ -0,0 +1,194 @@
+package output
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/mihaichiorean/monidog/reporter"
+)
+
+// DefaultQueueSize is how many events an output buffers for its sink. Events
+// coming in while the queue is full are dropped
+const DefaultQueueSize = 1000
+
+// DefaultFlushInterval is how often sinks are flushed
+const DefaultFlushInterval = time.Second
+
+// Event is what is shipped to sinks: either a parsed log or a report
+type Event struct {
+	Log    parser.Log
+	Report *reporter.Report
+}
+
+// Time is when the event happened: the time of the log or the end of the report
+func (e Event) Time() time.Time {
+	if e.Report != nil {
+		return e.Report.End
+	}
+	return e.Log.Timestamp()
+}
+
+// Sink is a destination for events. Its methods are only called from the
+// goroutine of the output running it, so sinks need no locking
+type Sink interface {
+	Write(e Event) error
+	// Flush sends buffered events. It is called every flush interval and
+	// before Close
+	Flush() error
+	Close() error
+}
+
+// Stats is how an output is doing
+type Stats struct {
+	// Written is how many events the sink accepted
+	Written int
+	// Dropped is how many events were dropped because the queue was full
+	Dropped int
+	// Errors is how many writes and flushes failed, LastError the last failure
+	Errors    int
+	LastError string
+}
+
+// Output ships logs and reports to a sink from its own goroutine, through a
+// bounded queue: a slow or failing sink drops events instead of blocking the
+// scanner and reporter it reads from, and through them the alerts
+type Output struct {
+	name       string
+	sink       Sink
+	queueSize  int
+	flushEvery time.Duration
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New constructs an output to sink, named after its destination
+func New(name string, sink Sink) *Output {
+	o := Output{
+		name:       name,
+		sink:       sink,
+		queueSize:  DefaultQueueSize,
+		flushEvery: DefaultFlushInterval,
+	}
+	return &o
+}
+
+// Name returns the name of the output
+func (o *Output) Name() string {
+	return o.name
+}
+
+// SetQueueSize sets how many events are buffered for the sink. It must be
+// called before starting
+func (o *Output) SetQueueSize(n int) {
+	o.queueSize = n
+}
+
+// SetFlushInterval sets how often the sink is flushed. It must be called before
+// starting
+func (o *Output) SetFlushInterval(d time.Duration) {
+	o.flushEvery = d
+}
+
+// Stats returns how the output is doing. It is safe to call while it runs
+func (o *Output) Stats() Stats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.stats
+}
+
+// Start ships the logs and reports received on their channels, either of which
+// may be nil, until both close or the returned function is called. The function
+// waits for the queued events to be written and the sink to be closed, and
+// returns the error closing it. Once stopped the channels are still drained, so
+// they never block their sender
+func (o *Output) Start(logs <-chan parser.Log, reports <-chan reporter.Report) func() error {
+	queue := make(chan Event, o.queueSize)
+	done := make(chan struct{})
+	closed := make(chan error, 1)
+	go o.enqueue(logs, reports, queue, done)
+	go func() {
+		closed <- o.write(queue)
+	}()
+
+	var once sync.Once
+	var err error
+	return func() error {
+		once.Do(func() {
+			close(done)
+			err = <-closed
+		})
+		return err
+	}
+}
+
+// enqueue queues the incoming events without ever blocking on the queue
+func (o *Output) enqueue(logs <-chan parser.Log, reports <-chan reporter.Report, queue chan Event, done <-chan struct{}) {
+	for logs != nil || reports != nil {
+		var e Event
+		select {
+		case l, ok := <-logs:
+			if !ok {
+				logs = nil
+				continue
+			}
+			e = Event{Log: l}
+		case rep, ok := <-reports:
+			if !ok {
+				reports = nil
+				continue
+			}
+			e = Event{Report: &rep}
+		case <-done:
+			close(queue)
+			queue, done = nil, nil
+			continue
+		}
+		if queue == nil {
+			continue
+		}
+		select {
+		case queue <- e:
+		default:
+			o.mu.Lock()
+			o.stats.Dropped++
+			o.mu.Unlock()
+		}
+	}
+	if queue != nil {
+		close(queue)
+	}
+}
+
+// write hands the queued events to the sink, flushing it every interval, and
+// closes it once the queue is closed
+func (o *Output) write(queue <-chan Event) error {
+	t := time.NewTicker(o.flushEvery)
+	defer t.Stop()
+	for {
+		select {
+		case e, ok := <-queue:
+			if !ok {
+				o.record(o.sink.Flush(), false)
+				return o.sink.Close()
+			}
+			o.record(o.sink.Write(e), true)
+		case <-t.C:
+			o.record(o.sink.Flush(), false)
+		}
+	}
+}
+
+func (o *Output) record(err error, written bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		o.stats.Errors++
+		o.stats.LastError = err.Error()
+		return
+	}
+	if written {
+		o.stats.Written++
+	}
+}
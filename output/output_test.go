@@ -0,0 +1,207 @@
+package output
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/mihaichiorean/monidog/mocks"
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/stretchr/testify/assert"
+)
+
+var ts = time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+
+func httpLog(ctrl *gomock.Controller, resource string, status int) *mocks.MockHTTPLog {
+	l := mocks.NewMockHTTPLog(ctrl)
+	l.EXPECT().Timestamp().Return(ts).AnyTimes()
+	l.EXPECT().Resource().Return(resource).AnyTimes()
+	l.EXPECT().Host().Return("10.0.0.1").AnyTimes()
+	l.EXPECT().User().Return("frank").AnyTimes()
+	l.EXPECT().Method().Return("GET").AnyTimes()
+	l.EXPECT().Protocol().Return("HTTP/1.1").AnyTimes()
+	l.EXPECT().Status().Return(status).AnyTimes()
+	l.EXPECT().Size().Return(uint64(512)).AnyTimes()
+	l.EXPECT().Referer().Return("-").AnyTimes()
+	l.EXPECT().UserAgent().Return("curl/7.54.0").AnyTimes()
+	l.EXPECT().VirtualHost().Return("").AnyTimes()
+	return l
+}
+
+// fakeSink records events, blocking writes until released
+type fakeSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	events  []Event
+	flushes int
+	closed  bool
+}
+
+func (s *fakeSink) Write(e Event) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *fakeSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func Test_Output(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sink := &fakeSink{release: make(chan struct{})}
+	o := New("fake", sink)
+	o.SetQueueSize(2)
+	o.SetFlushInterval(time.Millisecond)
+	logs := make(chan parser.Log)
+	reports := make(chan reporter.Report)
+	stop := o.Start(logs, reports)
+
+	// a stuck sink doesn't block the senders
+	l := httpLog(ctrl, "/api/user", 200)
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			logs <- l
+		}
+		reports <- reporter.Report{End: ts}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("blocked by the sink")
+	}
+
+	close(sink.release)
+	close(logs)
+	close(reports)
+	assert.NoError(t, stop())
+	assert.NoError(t, stop())
+
+	s := o.Stats()
+	assert.Equal(t, "fake", o.Name())
+	assert.Equal(t, 11, s.Written+s.Dropped)
+	// the sink holds one, the queue two and the last one may come in once released
+	assert.True(t, s.Dropped >= 7, "%+v", s)
+	assert.Len(t, sink.events, s.Written)
+	assert.True(t, sink.closed)
+	assert.True(t, sink.flushes > 0)
+}
+
+func Test_Output_stop(t *testing.T) {
+	sink := &fakeSink{release: make(chan struct{})}
+	close(sink.release)
+	o := New("fake", sink)
+	logs := make(chan parser.Log)
+	stop := o.Start(logs, nil)
+	assert.NoError(t, stop())
+	assert.True(t, sink.closed)
+
+	// still drained once stopped
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	select {
+	case logs <- httpLog(ctrl, "/", 200):
+	case <-time.After(time.Second):
+		t.Fatal("not drained")
+	}
+	close(logs)
+}
+
+func Test_Encode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	b, err := Encode(Event{Log: httpLog(ctrl, "/api/user", 404)})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"log","timestamp":"2018-11-10T12:00:00Z","resource":"/api/user","host":"10.0.0.1","user":"frank","method":"GET","protocol":"HTTP/1.1","status":404,"size":512,"referer":"-","user_agent":"curl/7.54.0"}`, string(b))
+
+	plain := mocks.NewMockLog(ctrl)
+	plain.EXPECT().Timestamp().Return(ts)
+	plain.EXPECT().Resource().Return("/")
+	b, err = Encode(Event{Log: plain})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"log","timestamp":"2018-11-10T12:00:00Z","resource":"/"}`, string(b))
+
+	// fields beyond HTTPLog, like the request time, are kept
+	p, err := parser.ParseLogFormat("json:latency=duration_ms,latency_unit=ms")
+	assert.NoError(t, err)
+	l, err := p.Parse(`{"time": "2018-11-10T12:00:00Z", "path": "/api/user", "status": 200, "duration_ms": 250}`)
+	assert.NoError(t, err)
+	b, err = Encode(Event{Log: l})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"log","timestamp":"2018-11-10T12:00:00Z","resource":"/api/user","status":200,"fields":{"latency":250,"request_time":0.25}}`, string(b))
+
+	b, err = Encode(Event{Report: &reporter.Report{Start: ts, End: ts.Add(10 * time.Second), Window: 10 * time.Second}})
+	assert.NoError(t, err)
+	var rep map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &rep))
+	assert.Equal(t, "report", rep["type"])
+	assert.Equal(t, "2018-11-10T12:00:10Z", rep["end"])
+}
+
+func Test_ParseSink(t *testing.T) {
+	dir := tempDir(t)
+	defer cleanup(dir)
+
+	s, err := ParseSink("file:" + dir + "/out.log?max_size=10&backups=2")
+	assert.NoError(t, err)
+	f := s.(*FileSink)
+	assert.Equal(t, dir+"/out.log", f.path)
+	assert.Equal(t, int64(10), f.maxSize)
+	assert.Equal(t, 2, f.backups)
+	f.Close()
+
+	s, err = ParseSink("syslog+unixgram:///dev/log")
+	assert.NoError(t, err)
+	assert.Equal(t, "unixgram", s.(*SyslogSink).network)
+	assert.Equal(t, "/dev/log", s.(*SyslogSink).addr)
+	s, err = ParseSink("syslog+udp://localhost:514")
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:514", s.(*SyslogSink).addr)
+
+	s, err = ParseSink("tcp://localhost:9000?batch=10&retries=1")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, s.(*TCPSink).size)
+	assert.Equal(t, 1, s.(*TCPSink).retries)
+
+	s, err = ParseSink("https://example.com/logs?token=x&batch=5")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/logs?token=x", s.(*HTTPSink).url)
+	assert.Equal(t, 5, s.(*HTTPSink).size)
+	assert.Equal(t, DefaultRetries, s.(*HTTPSink).retries)
+
+	s, err = ParseSink("dogstatsd://localhost:8125")
+	assert.NoError(t, err)
+	assert.True(t, s.(*StatsDSink).dogstatsd)
+	assert.Equal(t, "monidog", s.(*StatsDSink).prefix)
+	s.Close()
+	s, err = ParseSink("statsd://localhost:8125?prefix=")
+	assert.NoError(t, err)
+	assert.False(t, s.(*StatsDSink).dogstatsd)
+	assert.Equal(t, "", s.(*StatsDSink).prefix)
+	s.Close()
+
+	for _, spec := range []string{"kafka://localhost", "file:", "tcp://localhost:9000?batch=x", "syslog+sctp://localhost"} {
+		_, err = ParseSink(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseSink builds a sink from a URL-like spec:
+//
+//	file:PATH?max_size=BYTES&backups=N             rotated JSON lines file
+//	syslog+NETWORK://ADDR                          udp, tcp, unix or unixgram, e.g. syslog+unixgram:///dev/log
+//	tcp://HOST:PORT?batch=N&retries=N              batched JSON lines
+//	http(s)://HOST/PATH?batch=N&retries=N          batched JSON lines posted to the URL
+//	statsd://HOST:PORT?prefix=P, dogstatsd://...   report counters and alert gauges, prefix defaults to monidog
+func ParseSink(spec string) (Sink, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid sink %s", spec)
+	}
+	q := u.Query()
+	intParam := func(name string, def int) (int, error) {
+		v := q.Get(name)
+		q.Del(name)
+		if v == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s %q in sink %s", name, v, spec)
+		}
+		return n, nil
+	}
+	batching := func(b *batcher) error {
+		size, err := intParam("batch", DefaultBatchSize)
+		if err != nil {
+			return err
+		}
+		retries, err := intParam("retries", DefaultRetries)
+		if err != nil {
+			return err
+		}
+		if size > 0 {
+			b.SetBatchSize(size)
+		}
+		b.SetRetries(retries, DefaultBackoff)
+		return nil
+	}
+
+	switch {
+	case u.Scheme == "file":
+		path := u.Path
+		if u.Opaque != "" {
+			path = u.Opaque
+		}
+		if path == "" {
+			return nil, fmt.Errorf("file sink %s has no path", spec)
+		}
+		maxSize, err := intParam("max_size", DefaultMaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		backups, err := intParam("backups", DefaultBackups)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileSink(path, int64(maxSize), backups)
+	case strings.HasPrefix(u.Scheme, "syslog+"):
+		addr := u.Host
+		if addr == "" {
+			addr = u.Path
+		}
+		return NewSyslogSink(strings.TrimPrefix(u.Scheme, "syslog+"), addr)
+	case u.Scheme == "tcp":
+		s := NewTCPSink(u.Host)
+		if err := batching(&s.batcher); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case u.Scheme == "http" || u.Scheme == "https":
+		// the batching parameters are not sent to the endpoint
+		var b batcher
+		if err := batching(&b); err != nil {
+			return nil, err
+		}
+		u.RawQuery = q.Encode()
+		s := NewHTTPSink(u.String())
+		s.SetBatchSize(b.size)
+		s.SetRetries(b.retries, b.backoff)
+		return s, nil
+	case u.Scheme == "statsd" || u.Scheme == "dogstatsd":
+		prefix := "monidog"
+		if _, ok := q["prefix"]; ok {
+			prefix = q.Get("prefix")
+		}
+		return NewStatsDSink(u.Host, prefix, u.Scheme == "dogstatsd")
+	}
+	return nil, fmt.Errorf("unknown sink %s, expected file:, syslog+udp://, syslog+tcp://, syslog+unix://, syslog+unixgram://, tcp://, http(s)://, statsd:// or dogstatsd://", spec)
+}
@@ -0,0 +1,227 @@
+package output
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/pkg/errors"
+)
+
+// largest StatsD packet sent, to fit a typical MTU
+const maxPacketSize = 1432
+
+// metric is a StatsD metric aggregated between flushes
+type metric struct {
+	name  string
+	tags  []string
+	kind  string
+	value float64
+}
+
+// StatsDSink sends the counts of the reports and the state of alerts to a
+// StatsD server over UDP. Counts are summed between flushes and sent every
+// flush interval, as few packets as fit them, rather than one packet per log.
+// With DogStatsD tags are sent as such, e.g.
+// "monidog.responses:3|c|#section:/api,status:2xx", else their values are
+// appended to the metric name, "monidog.responses./api.2xx:3|c"
+//
+// Per section, it counts hits, responses by status class, requests by method
+// and bytes. Only the reports of one window are counted, the first one it gets
+// unless set with SetWindow, since coarser windows are rolled up from it and
+// would count hits twice. Logs are not counted, the reporter counts them
+// already
+type StatsDSink struct {
+	addr      string
+	prefix    string
+	dogstatsd bool
+	conn      net.Conn
+	metrics   map[string]*metric
+	window    time.Duration
+	states    func() []alerts.State
+}
+
+// NewStatsDSink constructs a StatsD sink to a UDP address. Metric names start
+// with prefix, if set
+func NewStatsDSink(addr, prefix string, dogstatsd bool) (*StatsDSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve statsd %s", addr)
+	}
+	s := StatsDSink{
+		addr:      addr,
+		prefix:    prefix,
+		dogstatsd: dogstatsd,
+		conn:      conn,
+		metrics:   map[string]*metric{},
+	}
+	return &s, nil
+}
+
+// SetWindow sets the window whose reports are counted. It must be called
+// before the sink is written to
+func (s *StatsDSink) SetWindow(d time.Duration) {
+	s.window = d
+}
+
+// SetAlerts makes every flush send the state of the alerts returned by states,
+// e.g. Manager.States, as gauges: alert.active (0 or 1), alert.count and
+// alert.threshold, tagged with the alert name. Compound alerts send the count of
+// each condition as alert.condition instead of a count and threshold. It must
+// be called before the sink is written to
+func (s *StatsDSink) SetAlerts(states func() []alerts.State) {
+	s.states = states
+}
+
+// add aggregates a value: counts are summed, gauges keep the last value. Tags
+// are name:value pairs
+func (s *StatsDSink) add(kind, name string, v float64, tags ...string) {
+	key := kind + "|" + name + "|" + strings.Join(tags, ",")
+	m, ok := s.metrics[key]
+	if !ok {
+		m = &metric{name: name, tags: tags, kind: kind}
+		s.metrics[key] = m
+	}
+	if kind == "c" {
+		m.value += v
+	} else {
+		m.value = v
+	}
+}
+
+// Write counts the sections of a report
+func (s *StatsDSink) Write(e Event) error {
+	if e.Report == nil {
+		return nil
+	}
+	if s.window == 0 {
+		s.window = e.Report.Window
+	}
+	if e.Report.Window != s.window {
+		return nil
+	}
+	for _, sec := range e.Report.Sections {
+		tag := "section:" + sec.Name
+		s.add("c", "hits", float64(sec.Hits), tag)
+		s.add("c", "bytes", float64(sec.Stats.Bytes), tag)
+		for class, n := range sec.Stats.Statuses {
+			s.add("c", "responses", float64(n), tag, "status:"+class)
+		}
+		for method, n := range sec.Stats.Methods {
+			s.add("c", "requests", float64(n), tag, "method:"+method)
+		}
+	}
+	return nil
+}
+
+// gaugeAlerts adds the state of the alerts
+func (s *StatsDSink) gaugeAlerts() {
+	if s.states == nil {
+		return
+	}
+	for _, st := range s.states() {
+		tag := "alert:" + st.Name
+		active := 0.0
+		if st.Active {
+			active = 1
+		}
+		s.add("g", "alert.active", active, tag)
+		if st.Expr != "" {
+			// compound alerts have no threshold, their conditions are compared
+			for name, v := range st.Values {
+				s.add("g", "alert.condition", v, tag, "condition:"+name)
+			}
+			continue
+		}
+		s.add("g", "alert.count", float64(st.Count), tag)
+		s.add("g", "alert.threshold", float64(st.Threshold), tag)
+	}
+}
+
+// line formats a metric in the StatsD line protocol
+func (s *StatsDSink) line(m *metric) string {
+	name := m.name
+	if s.prefix != "" {
+		name = s.prefix + "." + name
+	}
+	value := strconv.FormatFloat(m.value, 'f', -1, 64)
+	if s.dogstatsd {
+		line := fmt.Sprintf("%s:%s|%s", name, value, m.kind)
+		if len(m.tags) > 0 {
+			tags := make([]string, len(m.tags))
+			for i, t := range m.tags {
+				tags[i] = sanitize(t, true)
+			}
+			line += "|#" + strings.Join(tags, ",")
+		}
+		return line
+	}
+	for _, t := range m.tags {
+		name += "." + sanitize(t[strings.Index(t, ":")+1:], false)
+	}
+	return fmt.Sprintf("%s:%s|%s", name, value, m.kind)
+}
+
+// sanitize replaces the characters StatsD gives a meaning to
+func sanitize(s string, tag bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ':' && tag:
+			return r
+		case r == ':', r == '|', r == '@', r == '#', r == ',', r == '\n', r == ' ':
+			return '_'
+		case r == '.' && !tag:
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Flush sends the aggregated metrics, as few packets as fit them, and starts
+// over
+func (s *StatsDSink) Flush() error {
+	s.gaugeAlerts()
+	if len(s.metrics) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(s.metrics))
+	for _, m := range s.metrics {
+		lines = append(lines, s.line(m))
+	}
+	sort.Strings(lines)
+	s.metrics = map[string]*metric{}
+
+	var err error
+	packet := ""
+	send := func() {
+		if packet == "" {
+			return
+		}
+		if _, werr := s.conn.Write([]byte(packet)); werr != nil {
+			err = errors.Wrapf(werr, "failed to send to statsd %s", s.addr)
+		}
+		packet = ""
+	}
+	for _, l := range lines {
+		if packet != "" && len(packet)+1+len(l) > maxPacketSize {
+			send()
+		}
+		if packet != "" {
+			packet += "\n"
+		}
+		packet += l
+	}
+	send()
+	return err
+}
+
+// Close sends the aggregated metrics and closes the socket
+func (s *StatsDSink) Close() error {
+	err := s.Flush()
+	s.conn.Close()
+	return err
+}
@@ -0,0 +1,135 @@
+package output
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/mihaichiorean/monidog/alerts"
+	"github.com/mihaichiorean/monidog/reporter/reportertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// receive reads the packets sent to pc until none comes for a while
+func receive(t *testing.T, pc net.PacketConn) []string {
+	packets := []string{}
+	buf := make([]byte, 64<<10)
+	for {
+		pc.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			return packets
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func Test_StatsDSink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+
+	rep := reportertest.Report(reportertest.Start, 10*time.Second,
+		reportertest.Section("/api", map[string]int{"2xx": 3, "5xx": 1}))
+	rollup := reportertest.Report(reportertest.Start, time.Minute, rep.Sections...)
+	states := []alerts.State{
+		{Name: "high traffic", Active: true, Count: 12, Threshold: 10},
+		{Name: "api errors", Count: 20, Expr: "errors > 2", Values: map[string]float64{"errors": 3}},
+	}
+
+	for _, dogstatsd := range []bool{true, false} {
+		s, err := NewStatsDSink(pc.LocalAddr().String(), "monidog", dogstatsd)
+		assert.NoError(t, err)
+		s.SetAlerts(func() []alerts.State { return states })
+		// aggregated until flushed
+		assert.NoError(t, s.Write(Event{Report: &rep}))
+		assert.NoError(t, s.Write(Event{Report: &rollup}))
+		assert.NoError(t, s.Write(Event{Report: &rep}))
+		// logs are counted by the reporter
+		assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/api/user", 200)}))
+		assert.Empty(t, receive(t, pc))
+		assert.NoError(t, s.Flush())
+
+		packets := receive(t, pc)
+		assert.Len(t, packets, 1)
+		if dogstatsd {
+			assert.Equal(t, strings.Join([]string{
+				"monidog.alert.active:0|g|#alert:api_errors",
+				"monidog.alert.active:1|g|#alert:high_traffic",
+				"monidog.alert.condition:3|g|#alert:api_errors,condition:errors",
+				"monidog.alert.count:12|g|#alert:high_traffic",
+				"monidog.alert.threshold:10|g|#alert:high_traffic",
+				"monidog.bytes:800|c|#section:/api",
+				"monidog.hits:8|c|#section:/api",
+				"monidog.requests:8|c|#section:/api,method:GET",
+				"monidog.responses:2|c|#section:/api,status:5xx",
+				"monidog.responses:6|c|#section:/api,status:2xx",
+			}, "\n"), packets[0])
+		} else {
+			assert.Equal(t, strings.Join([]string{
+				"monidog.alert.active.api_errors:0|g",
+				"monidog.alert.active.high_traffic:1|g",
+				"monidog.alert.condition.api_errors.errors:3|g",
+				"monidog.alert.count.high_traffic:12|g",
+				"monidog.alert.threshold.high_traffic:10|g",
+				"monidog.bytes./api:800|c",
+				"monidog.hits./api:8|c",
+				"monidog.requests./api.GET:8|c",
+				"monidog.responses./api.2xx:6|c",
+				"monidog.responses./api.5xx:2|c",
+			}, "\n"), packets[0])
+		}
+
+		// counts start over after a flush, gauges are sent every time
+		assert.NoError(t, s.Flush())
+		packets = receive(t, pc)
+		assert.Len(t, packets, 1)
+		assert.Len(t, strings.Split(packets[0], "\n"), 5)
+		assert.NoError(t, s.Close())
+		receive(t, pc)
+	}
+
+	// a set window
+	s, err := NewStatsDSink(pc.LocalAddr().String(), "", true)
+	assert.NoError(t, err)
+	s.SetWindow(time.Minute)
+	assert.NoError(t, s.Write(Event{Report: &rep}))
+	assert.NoError(t, s.Write(Event{Report: &rollup}))
+	assert.NoError(t, s.Close())
+	assert.Contains(t, receive(t, pc)[0], "hits:4|c|#section:/api")
+}
+
+func Test_StatsDSink_packets(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+
+	s, err := NewStatsDSink(pc.LocalAddr().String(), "", true)
+	assert.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		s.add("c", "requests", 1, "section:/"+strings.Repeat("x", i%50)+string(rune('a'+i/50)))
+	}
+	assert.NoError(t, s.Close())
+
+	packets := receive(t, pc)
+	assert.True(t, len(packets) > 1)
+	lines := []string{}
+	for _, p := range packets {
+		assert.True(t, len(p) <= maxPacketSize)
+		lines = append(lines, strings.Split(p, "\n")...)
+	}
+	assert.Len(t, lines, 200)
+	assert.True(t, sort.StringsAreSorted(lines))
+}
+
+func Test_sanitize(t *testing.T) {
+	assert.Equal(t, "section:/a_b_c", sanitize("section:/a|b,c", true))
+	assert.Equal(t, "_api_v1", sanitize(".api.v1", false))
+	assert.Equal(t, "a_b", sanitize("a:b", false))
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// syslog priority of the messages: facility local0, severity informational
+const syslogPriority = 16*8 + 6
+
+// how long connecting and writing to a network sink may take
+const networkTimeout = 5 * time.Second
+
+// SyslogSink sends events as RFC 5424 syslog messages whose text is the JSON of
+// the event, with the message id "log" or "report". Over udp and unixgram each
+// message is a datagram; over tcp and unix streams messages are framed with
+// their length, as in RFC 6587
+type SyslogSink struct {
+	network  string
+	addr     string
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslogSink constructs a syslog sink to addr over network: udp, tcp, unix or
+// unixgram. It connects on the first write and reconnects after errors
+func NewSyslogSink(network, addr string) (*SyslogSink, error) {
+	switch network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q, expected udp, tcp, unix or unixgram", network)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	s := SyslogSink{
+		network:  network,
+		addr:     addr,
+		hostname: hostname,
+	}
+	return &s, nil
+}
+
+// message formats an event as a syslog message
+func (s *SyslogSink) message(e Event) ([]byte, error) {
+	b, err := Encode(e)
+	if err != nil {
+		return nil, err
+	}
+	msgid := "log"
+	if e.Report != nil {
+		msgid = "report"
+	}
+	ts := e.Time().Format("2006-01-02T15:04:05.000000Z07:00")
+	msg := fmt.Sprintf("<%d>1 %s %s monidog %d %s - %s", syslogPriority, ts, s.hostname, os.Getpid(), msgid, b)
+	if s.network == "tcp" || s.network == "unix" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	return []byte(msg), nil
+}
+
+// Write sends an event
+func (s *SyslogSink) Write(e Event) error {
+	msg, err := s.message(e)
+	if err != nil {
+		return err
+	}
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, networkTimeout)
+		if err != nil {
+			return errors.Wrapf(err, "failed to connect to syslog %s", s.addr)
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(networkTimeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return errors.Wrapf(err, "failed to send to syslog %s", s.addr)
+	}
+	return nil
+}
+
+// Flush does nothing, messages are sent as they are written
+func (s *SyslogSink) Flush() error {
+	return nil
+}
+
+// Close closes the connection
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,91 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/mihaichiorean/monidog/reporter"
+	"github.com/stretchr/testify/assert"
+)
+
+var syslogHeader = regexp.MustCompile(`^<134>1 2018-11-10T12:00:00\.000000Z \S+ monidog \d+ (log|report) - \{`)
+
+func Test_SyslogSink_udp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+
+	s, err := NewSyslogSink("udp", pc.LocalAddr().String())
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/api/user", 200)}))
+	assert.NoError(t, s.Write(Event{Report: &reporter.Report{End: ts}}))
+
+	buf := make([]byte, 64<<10)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.NoError(t, err)
+	msg := string(buf[:n])
+	assert.Regexp(t, syslogHeader, msg)
+	assert.Contains(t, msg, fmt.Sprintf(" monidog %d log - ", os.Getpid()))
+	assert.True(t, strings.HasSuffix(msg, `"user_agent":"curl/7.54.0"}`))
+
+	n, _, err = pc.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf[:n]), " report - {\"type\":\"report\"")
+	assert.NoError(t, s.Close())
+}
+
+func Test_SyslogSink_tcp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			// octet counting: the length, a space, then the message
+			var n int
+			if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	s, err := NewSyslogSink("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/api/user", 200)}))
+	assert.NoError(t, s.Write(Event{Log: httpLog(ctrl, "/api/order", 500)}))
+	assert.Regexp(t, syslogHeader, <-received)
+	assert.Contains(t, <-received, `"resource":"/api/order"`)
+	assert.NoError(t, s.Close())
+
+	// the listener is gone, the write fails and the next one reconnects
+	ln.Close()
+	assert.Error(t, s.Write(Event{Log: httpLog(ctrl, "/", 200)}))
+
+	_, err = NewSyslogSink("sctp", "localhost:514")
+	assert.Error(t, err)
+}
@@ -0,0 +1,535 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Extra fields of logs parsed with a log format, besides those of HTTPLog.
+// Times are float64 seconds; other variables of the format can be looked up by
+// their nginx name as logged, e.g. "http_x_forwarded_for"
+const (
+	FieldRequestTime          = "request_time"
+	FieldUpstreamResponseTime = "upstream_response_time"
+	FieldForwardedFor         = "http_x_forwarded_for"
+)
+
+// nginx variables of the formats whose values are parsed into HTTPLog
+var knownVariables = map[string]bool{
+	"remote_addr":     true,
+	"remote_user":     true,
+	"time_local":      true,
+	"time_iso8601":    true,
+	"msec":            true,
+	"request":         true,
+	"request_method":  true,
+	"request_uri":     true,
+	"uri":             true,
+	"args":            true,
+	"query_string":    true,
+	"server_protocol": true,
+	"status":          true,
+	"body_bytes_sent": true,
+	"bytes_sent":      true,
+	"http_referer":    true,
+	"http_user_agent": true,
+	"host":            true,
+	"server_name":     true,
+}
+
+// Apache format directives and the nginx variables they stand for
+var apacheDirectives = map[byte]string{
+	'a': "remote_addr",
+	'h': "remote_addr",
+	'l': "remote_logname",
+	'u': "remote_user",
+	'r': "request",
+	's': "status",
+	'b': "body_bytes_sent",
+	'B': "body_bytes_sent",
+	'O': "bytes_sent",
+	'D': "request_time_us",
+	'T': "request_time",
+	'v': "server_name",
+	'V': "host",
+	'm': "request_method",
+	'U': "uri",
+	'q': "query_string",
+	'H': "server_protocol",
+}
+
+// token is either a literal or a variable of a log format
+type token struct {
+	literal  string
+	variable string
+}
+
+// FormatParser parses logs written with a custom nginx log_format or Apache
+// LogFormat. The format is compiled once into literals and variables; lines are
+// matched by looking for the literal that ends each variable, so a variable
+// can't contain the text that follows it in the format
+type FormatParser struct {
+	format string
+	tokens []token
+}
+
+// NewNginxParser compiles an nginx log_format, either the format itself or the
+// whole directive, e.g.
+//
+//	log_format main '$remote_addr - $remote_user [$time_local] "$request" '
+//	                '$status $body_bytes_sent "$http_referer" "$http_user_agent" $request_time';
+func NewNginxParser(format string) (*FormatParser, error) {
+	format = nginxDirective(format)
+	var tokens []token
+	lit := ""
+	for i := 0; i < len(format); i++ {
+		if format[i] != '$' {
+			lit += format[i : i+1]
+			continue
+		}
+		name, n := nginxVariable(format[i+1:])
+		if name == "" {
+			lit += "$"
+			continue
+		}
+		tokens = appendToken(tokens, lit, name)
+		lit = ""
+		i += n
+	}
+	return newFormatParser(format, tokens, lit)
+}
+
+// NewApacheParser compiles an Apache LogFormat, either the format itself or the
+// whole directive, e.g.
+//
+//	LogFormat "%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-agent}i\" %D" combined
+func NewApacheParser(format string) (*FormatParser, error) {
+	format = apacheDirective(format)
+	var tokens []token
+	lit := ""
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c == '\\' && i+1 < len(format) {
+			i++
+			switch format[i] {
+			case 't':
+				lit += "\t"
+			case 'n':
+				lit += "\n"
+			default:
+				lit += format[i : i+1]
+			}
+			continue
+		}
+		if c != '%' {
+			lit += format[i : i+1]
+			continue
+		}
+		if i+1 == len(format) {
+			return nil, fmt.Errorf("invalid log format %q: trailing %%", format)
+		}
+		i++
+		if format[i] == '%' {
+			lit += "%"
+			continue
+		}
+		// status and other directives may ask for the original or final
+		// request, which the log doesn't tell apart
+		for format[i] == '<' || format[i] == '>' {
+			if i+1 == len(format) {
+				return nil, fmt.Errorf("invalid log format %q: trailing %%", format)
+			}
+			i++
+		}
+		arg := ""
+		if format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 || i+end+1 == len(format) {
+				return nil, fmt.Errorf("invalid log format %q: unterminated %%{", format)
+			}
+			arg = format[i+1 : i+end]
+			i += end + 1
+		}
+		var name string
+		c = format[i]
+		switch {
+		case c == 't' && arg == "":
+			// %t is the local time in brackets
+			tokens = appendToken(tokens, lit+"[", "time_local")
+			lit = "]"
+			continue
+		case c == 'i' && arg != "":
+			name = "http_" + headerVariable(arg)
+		case c == 'o' && arg != "":
+			name = "sent_http_" + headerVariable(arg)
+		case arg == "" && apacheDirectives[c] != "":
+			name = apacheDirectives[c]
+		default:
+			return nil, fmt.Errorf("unsupported directive %%%s%c in log format %q", arg, c, format)
+		}
+		tokens = appendToken(tokens, lit, name)
+		lit = ""
+	}
+	return newFormatParser(format, tokens, lit)
+}
+
+// ParseLogFormat builds a parser from a spec, as given on the command line:
+//
+//	combined        common and combined access logs, and LTSV (default)
+//	nginx:FORMAT    an nginx log_format
+//	apache:FORMAT   an Apache LogFormat
+//	json:OPTIONS    JSON lines, with fields mapped as in name=path,layout=LAYOUT
+func ParseLogFormat(spec string) (LogParser, error) {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, arg = spec[:i], spec[i+1:]
+	}
+	switch kind {
+	case "", "combined":
+		return NewAccessLogParser(), nil
+	case "nginx":
+		return NewNginxParser(arg)
+	case "apache":
+		return NewApacheParser(arg)
+	case "json":
+		return parseJSONSpec(arg)
+	}
+	return nil, fmt.Errorf("unknown log format %s, expected combined, nginx:FORMAT, apache:FORMAT or json:OPTIONS", spec)
+}
+
+// nginxDirective returns the format of a log_format directive, joining its
+// quoted strings, or the format as is if it isn't a directive
+func nginxDirective(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "log_format") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexAny(s, `'"`)
+		if start < 0 {
+			return b.String()
+		}
+		end := strings.IndexByte(s[start+1:], s[start])
+		if end < 0 {
+			return b.String() + s[start+1:]
+		}
+		b.WriteString(s[start+1 : start+1+end])
+		s = s[start+end+2:]
+	}
+}
+
+// nginxVariable returns the name of the variable at the start of s, as in
+// $name or ${name}, and how many bytes it takes
+func nginxVariable(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+	n := 0
+	for n < len(s) && (s[n] == '_' || s[n] >= 'a' && s[n] <= 'z' || s[n] >= 'A' && s[n] <= 'Z' || s[n] >= '0' && s[n] <= '9') {
+		n++
+	}
+	return s[:n], n
+}
+
+// apacheDirective returns the format of a LogFormat directive, without the
+// quotes and the nickname, or the format as is if it isn't a directive
+func apacheDirective(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "LogFormat") {
+		return s
+	}
+	s = strings.TrimSpace(strings.TrimPrefix(s, "LogFormat"))
+	if !strings.HasPrefix(s, `"`) {
+		return s
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return s[1:i]
+		}
+	}
+	return s[1:]
+}
+
+// headerVariable names a header like nginx does, e.g. X-Forwarded-For becomes
+// x_forwarded_for
+func headerVariable(header string) string {
+	return strings.Replace(strings.ToLower(header), "-", "_", -1)
+}
+
+// appendToken appends a literal, if any, and a variable
+func appendToken(tokens []token, literal, variable string) []token {
+	if literal != "" {
+		tokens = append(tokens, token{literal: literal})
+	}
+	return append(tokens, token{variable: variable})
+}
+
+func newFormatParser(format string, tokens []token, literal string) (*FormatParser, error) {
+	if literal != "" {
+		tokens = append(tokens, token{literal: literal})
+	}
+	vars := map[string]bool{}
+	for i, t := range tokens {
+		if t.variable == "" {
+			continue
+		}
+		if i > 0 && tokens[i-1].variable != "" {
+			return nil, fmt.Errorf("invalid log format %q: %s and %s are not separated", format, tokens[i-1].variable, t.variable)
+		}
+		vars[t.variable] = true
+	}
+	if !vars["time_local"] && !vars["time_iso8601"] && !vars["msec"] {
+		return nil, fmt.Errorf("invalid log format %q: no time", format)
+	}
+	if !vars["request"] && !vars["request_uri"] && !vars["uri"] {
+		return nil, fmt.Errorf("invalid log format %q: no request", format)
+	}
+	p := FormatParser{
+		format: format,
+		tokens: tokens,
+	}
+	return &p, nil
+}
+
+// Parse matches a line against the format
+func (p *FormatParser) Parse(line string) (Log, error) {
+	vars := make(map[string]string, len(p.tokens))
+	pos := 0
+	for i, t := range p.tokens {
+		if t.variable == "" {
+			if !strings.HasPrefix(line[pos:], t.literal) {
+				return nil, fmt.Errorf("failed to parse log line: expected %q at %d", t.literal, pos)
+			}
+			pos += len(t.literal)
+			continue
+		}
+		end := len(line)
+		if i+1 < len(p.tokens) {
+			n := strings.Index(line[pos:], p.tokens[i+1].literal)
+			if n < 0 {
+				return nil, fmt.Errorf("failed to parse log line: no %q after %s", p.tokens[i+1].literal, t.variable)
+			}
+			end = pos + n
+		}
+		vars[t.variable] = line[pos:end]
+		pos = end
+	}
+	if pos != len(line) {
+		return nil, fmt.Errorf("failed to parse log line: unexpected %q at %d", line[pos:], pos)
+	}
+	l := formatLog{vars: vars}
+	if err := l.parse(); err != nil {
+		return nil, errors.Wrap(err, "failed to parse log line")
+	}
+	return &l, nil
+}
+
+// formatLog is a log parsed with a log format. It keeps the variables as logged
+// and the HTTPLog fields parsed from them
+type formatLog struct {
+	vars map[string]string
+
+	time      time.Time
+	resource  string
+	host      string
+	user      string
+	method    string
+	protocol  string
+	referer   string
+	userAgent string
+	vhost     string
+	status    int
+	size      uint64
+}
+
+// value returns the first of the variables that is logged and not "-"
+func (l *formatLog) value(names ...string) string {
+	for _, n := range names {
+		if v := l.vars[n]; v != "" && v != "-" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (l *formatLog) parse() error {
+	var err error
+	switch {
+	case l.value("time_local") != "":
+		l.time, err = time.Parse("02/Jan/2006:15:04:05 -0700", l.value("time_local"))
+	case l.value("time_iso8601") != "":
+		l.time, err = time.Parse(time.RFC3339, l.value("time_iso8601"))
+	case l.value("msec") != "":
+		var sec float64
+		sec, err = strconv.ParseFloat(l.value("msec"), 64)
+		l.time = time.Unix(0, int64(sec*1e9))
+	default:
+		return fmt.Errorf("no time")
+	}
+	if err != nil {
+		return errors.Wrap(err, "invalid time")
+	}
+
+	if r := l.value("request"); r != "" {
+		parts := strings.Fields(r)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid request: %s", r)
+		}
+		l.method, l.resource, l.protocol = parts[0], parts[1], parts[2]
+	}
+	if l.resource == "" {
+		l.resource = l.value("request_uri")
+	}
+	if l.resource == "" {
+		l.resource = l.value("uri")
+		q := l.value("query_string")
+		if q == "" {
+			q = l.value("args")
+		}
+		// Apache's %q starts with the ?, nginx's $query_string and $args don't
+		if q != "" && !strings.HasPrefix(q, "?") {
+			q = "?" + q
+		}
+		l.resource += q
+	}
+	if l.resource == "" {
+		return fmt.Errorf("no request")
+	}
+	if l.method == "" {
+		l.method = l.value("request_method")
+	}
+	if l.protocol == "" {
+		l.protocol = l.value("server_protocol")
+	}
+
+	if s := l.value("status"); s != "" {
+		if l.status, err = strconv.Atoi(s); err != nil {
+			return errors.Wrap(err, "invalid status")
+		}
+	}
+	if s := l.value("body_bytes_sent", "bytes_sent"); s != "" {
+		if l.size, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return errors.Wrap(err, "invalid size")
+		}
+	}
+	l.host = l.value("remote_addr")
+	l.user = l.value("remote_user")
+	l.referer = l.value("http_referer")
+	l.userAgent = l.value("http_user_agent")
+	l.vhost = l.value("host", "server_name")
+	return nil
+}
+
+func (l *formatLog) Timestamp() time.Time {
+	return l.time
+}
+
+func (l *formatLog) Resource() string {
+	return l.resource
+}
+
+func (l *formatLog) Host() string {
+	return l.host
+}
+
+func (l *formatLog) User() string {
+	return l.user
+}
+
+func (l *formatLog) Method() string {
+	return l.method
+}
+
+func (l *formatLog) Protocol() string {
+	return l.protocol
+}
+
+func (l *formatLog) Referer() string {
+	return l.referer
+}
+
+func (l *formatLog) UserAgent() string {
+	return l.userAgent
+}
+
+func (l *formatLog) Status() int {
+	return l.status
+}
+
+func (l *formatLog) Size() uint64 {
+	return l.size
+}
+
+func (l *formatLog) VirtualHost() string {
+	return l.vhost
+}
+
+// Field returns the request and upstream response times in seconds, and the
+// other logged variables as strings. The fields of HTTPLog are left to it, some
+// variables, e.g. $host, don't mean the same
+func (l *formatLog) Field(name string) (interface{}, bool) {
+	switch name {
+	case FieldRequestTime:
+		if us := l.value("request_time_us"); us != "" {
+			v, err := strconv.ParseFloat(us, 64)
+			return v / 1e6, err == nil
+		}
+		return seconds(l.value(name))
+	case FieldUpstreamResponseTime:
+		return seconds(l.value(name))
+	}
+	if httpFields[name] || knownVariables[name] {
+		return nil, false
+	}
+	v, ok := l.vars[name]
+	return v, ok
+}
+
+// FieldNames lists the variables Field returns, with $request_time standing for
+// Apache's %D
+func (l *formatLog) FieldNames() []string {
+	names := []string{}
+	for name := range l.vars {
+		if name == "request_time_us" {
+			name = FieldRequestTime
+			if _, ok := l.vars[name]; ok {
+				continue
+			}
+		}
+		if !httpFields[name] && !knownVariables[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// seconds sums the times of a timing variable, e.g. "0.010, 0.020 : 0.005" when
+// several upstreams were tried. It is false if nothing was timed
+func seconds(s string) (interface{}, bool) {
+	if s == "" {
+		return nil, false
+	}
+	var total float64
+	timed := false
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ':' || r == ' ' }) {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			continue
+		}
+		total += v
+		timed = true
+	}
+	return total, timed
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewNginxParser(t *testing.T) {
+	p, err := NewNginxParser(`log_format main '$remote_addr - $remote_user [$time_local] "$request" '
+	                '$status $body_bytes_sent "$http_referer" "$http_user_agent" '
+	                '"$http_x_forwarded_for" $request_time "$upstream_response_time" $host';`)
+	assert.NoError(t, err)
+
+	l, err := p.Parse(`10.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /api/user?id=1 HTTP/1.1" 502 1024 "-" "curl/7.54.0" "203.0.113.7, 10.0.0.2" 0.250 "0.100, 0.120 : 0.010" example.com`)
+	assert.NoError(t, err)
+	h := l.(HTTPLog)
+	assert.True(t, time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC).Equal(h.Timestamp()))
+	assert.Equal(t, "/api/user?id=1", h.Resource())
+	assert.Equal(t, "10.0.0.1", h.Host())
+	assert.Equal(t, "frank", h.User())
+	assert.Equal(t, "GET", h.Method())
+	assert.Equal(t, "HTTP/1.1", h.Protocol())
+	assert.Equal(t, 502, h.Status())
+	assert.Equal(t, uint64(1024), h.Size())
+	assert.Equal(t, "", h.Referer())
+	assert.Equal(t, "curl/7.54.0", h.UserAgent())
+	assert.Equal(t, "example.com", h.VirtualHost())
+
+	v, ok := Field(l, FieldRequestTime)
+	assert.True(t, ok)
+	assert.Equal(t, 0.25, v)
+	v, ok = Field(l, FieldUpstreamResponseTime)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.23, v, 1e-9)
+	v, ok = Field(l, FieldForwardedFor)
+	assert.True(t, ok)
+	assert.Equal(t, "203.0.113.7, 10.0.0.2", v)
+	// $host is the virtual host, not the client
+	v, ok = Field(l, FieldHost)
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", v)
+	_, ok = Field(l, "sent_http_location")
+	assert.False(t, ok)
+
+	// a request that didn't reach an upstream
+	l, err = p.Parse(`10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 404 0 "-" "curl/7.54.0" "-" 0.001 "-" example.com`)
+	assert.NoError(t, err)
+	_, ok = Field(l, FieldUpstreamResponseTime)
+	assert.False(t, ok)
+	assert.Equal(t, "", l.(HTTPLog).User())
+
+	for _, line := range []string{
+		`10.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /"`,
+		`10.0.0.1 - frank [yesterday] "GET / HTTP/1.1" 200 0 "-" "-" "-" 0.1 "-" example.com`,
+		`10.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "garbage" 400 0 "-" "-" "-" 0.1 "-" example.com`,
+		`10.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" ok 0 "-" "-" "-" 0.1 "-" example.com`,
+	} {
+		_, err := p.Parse(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func Test_NewNginxParser_variables(t *testing.T) {
+	p, err := NewNginxParser(`${time_iso8601}|$request_method|$uri|$args|$status$msec`)
+	assert.Error(t, err)
+
+	p, err = NewNginxParser(`${time_iso8601}|$request_method|$uri|$args|$status|$server_protocol|$`)
+	assert.NoError(t, err)
+	l, err := p.Parse(`2018-11-10T12:00:00+00:00|POST|/api/user|id=1|201|HTTP/2.0|$`)
+	assert.NoError(t, err)
+	h := l.(HTTPLog)
+	assert.True(t, time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC).Equal(h.Timestamp()))
+	assert.Equal(t, "/api/user?id=1", h.Resource())
+	assert.Equal(t, "POST", h.Method())
+	assert.Equal(t, "HTTP/2.0", h.Protocol())
+	assert.Equal(t, 201, h.Status())
+
+	p, err = NewNginxParser(`$time_iso8601 $request_method $uri $query_string $status`)
+	assert.NoError(t, err)
+	l, err = p.Parse(`2018-11-10T12:00:00+00:00 GET /api/user id=1 200`)
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/user?id=1", l.(HTTPLog).Resource())
+
+	_, err = NewNginxParser(`$remote_addr $status`)
+	assert.Error(t, err)
+	_, err = NewNginxParser(`$time_local $status`)
+	assert.Error(t, err)
+}
+
+func Test_NewApacheParser(t *testing.T) {
+	p, err := NewApacheParser(`LogFormat "%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-agent}i\" \"%{X-Forwarded-For}i\" %D %V" vhost_combined`)
+	assert.NoError(t, err)
+
+	l, err := p.Parse(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 - "http://www.example.com/start.html" "Mozilla/4.08" "203.0.113.7" 1500 www.example.com`)
+	assert.NoError(t, err)
+	h := l.(HTTPLog)
+	assert.True(t, time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC).Equal(h.Timestamp()))
+	assert.Equal(t, "/apache_pb.gif", h.Resource())
+	assert.Equal(t, "127.0.0.1", h.Host())
+	assert.Equal(t, "frank", h.User())
+	assert.Equal(t, "GET", h.Method())
+	assert.Equal(t, "HTTP/1.0", h.Protocol())
+	assert.Equal(t, 200, h.Status())
+	assert.Equal(t, uint64(0), h.Size())
+	assert.Equal(t, "http://www.example.com/start.html", h.Referer())
+	assert.Equal(t, "Mozilla/4.08", h.UserAgent())
+	assert.Equal(t, "www.example.com", h.VirtualHost())
+
+	v, ok := Field(l, FieldRequestTime)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0015, v)
+	v, ok = Field(l, FieldForwardedFor)
+	assert.True(t, ok)
+	assert.Equal(t, "203.0.113.7", v)
+	assert.Equal(t, map[string]interface{}{
+		FieldRequestTime:  0.0015,
+		FieldForwardedFor: "203.0.113.7",
+		"remote_logname":  "-",
+	}, Fields(l))
+
+	p, err = NewApacheParser(`%t %m %U %q %>s`)
+	assert.NoError(t, err)
+	l, err = p.Parse(`[10/Oct/2000:13:55:36 -0700] GET /apache_pb.gif ?id=1 200`)
+	assert.NoError(t, err)
+	assert.Equal(t, "/apache_pb.gif?id=1", l.(HTTPLog).Resource())
+
+	_, err = NewApacheParser(`%h %t %{%Y}t "%r"`)
+	assert.Error(t, err)
+	_, err = NewApacheParser(`%t "%r" %`)
+	assert.Error(t, err)
+}
+
+func Test_ParseLogFormat(t *testing.T) {
+	p, err := ParseLogFormat("")
+	assert.NoError(t, err)
+	assert.IsType(t, &AccessLogParser{}, p)
+	p, err = ParseLogFormat("combined")
+	assert.NoError(t, err)
+	assert.IsType(t, &AccessLogParser{}, p)
+
+	p, err = ParseLogFormat(`nginx:$remote_addr [$time_local] "$request" $status`)
+	assert.NoError(t, err)
+	assert.IsType(t, &FormatParser{}, p)
+	p, err = ParseLogFormat(`apache:%h %t "%r" %>s`)
+	assert.NoError(t, err)
+	assert.IsType(t, &FormatParser{}, p)
+
+	_, err = ParseLogFormat("nginx:$status")
+	assert.Error(t, err)
+	_, err = ParseLogFormat("ltsv")
+	assert.Error(t, err)
+}
@@ -0,0 +1,355 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FieldLatency is the latency of a JSON log, a float64 in the unit the service
+// logs it in, see SetLatencyUnit. Logs with a latency also have it in seconds as
+// FieldRequestTime, unless that is mapped to a key of its own
+const FieldLatency = "latency"
+
+// Timestamp layouts of JSON logs besides Go time layouts
+const (
+	LayoutRFC3339 = "rfc3339"
+	LayoutUnix    = "unix"
+	LayoutUnixMs  = "unix_ms"
+	LayoutUnixUs  = "unix_us"
+	LayoutUnixNs  = "unix_ns"
+)
+
+// DefaultJSONFields are the keys of JSON logs that are used unless mapped
+// otherwise
+var DefaultJSONFields = map[string]string{
+	FieldTimestamp: "time",
+	FieldResource:  "path",
+	FieldStatus:    "status",
+	FieldMethod:    "method",
+	FieldLatency:   "latency",
+}
+
+// JSONParser parses logs written as one JSON object per line. Fields are mapped
+// to keys by dotted paths, e.g. "http.request.path", which also match keys that
+// contain dots themselves. A log needs a timestamp and a resource; the other
+// fields are left empty when their key is missing
+type JSONParser struct {
+	paths       map[string]string
+	layout      string
+	latencyUnit time.Duration
+}
+
+// NewJSONParser constructs a JSON parser mapping field names, such as
+// FieldResource or FieldLatency, to dotted paths. Fields that aren't mapped use
+// DefaultJSONFields; any other name is kept as is and can be looked up with
+// Field. The timestamp is parsed with layout: LayoutRFC3339, the epoch in
+// seconds, ms, us or ns with LayoutUnix and the like, or a Go time layout
+func NewJSONParser(fields map[string]string, layout string) (*JSONParser, error) {
+	paths := map[string]string{}
+	for name, path := range DefaultJSONFields {
+		paths[name] = path
+	}
+	for name, path := range fields {
+		if path == "" {
+			return nil, fmt.Errorf("empty path of json field %s", name)
+		}
+		paths[name] = path
+	}
+	if layout == "" {
+		layout = LayoutRFC3339
+	}
+	p := JSONParser{
+		paths:       paths,
+		layout:      layout,
+		latencyUnit: time.Second,
+	}
+	return &p, nil
+}
+
+// SetLatencyUnit sets the unit latencies are logged in, e.g. time.Millisecond
+// for a duration_ms key. Seconds by default
+func (p *JSONParser) SetLatencyUnit(unit time.Duration) {
+	p.latencyUnit = unit
+}
+
+// parseJSONSpec builds a JSON parser from the options of a log format spec,
+// name=path pairs separated by commas, layout=LAYOUT and latency_unit=UNIT
+func parseJSONSpec(spec string) (*JSONParser, error) {
+	fields := map[string]string{}
+	layout := ""
+	unit := time.Second
+	if spec == "" {
+		return NewJSONParser(fields, layout)
+	}
+	for _, opt := range strings.Split(spec, ",") {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid json option %q, expected name=path", opt)
+		}
+		switch kv[0] {
+		case "layout":
+			layout = kv[1]
+			continue
+		case "latency_unit":
+			d, err := time.ParseDuration("1" + kv[1])
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid latency_unit %q, expected s, ms, us or ns", kv[1])
+			}
+			unit = d
+			continue
+		}
+		fields[kv[0]] = kv[1]
+	}
+	p, err := NewJSONParser(fields, layout)
+	if err != nil {
+		return nil, err
+	}
+	p.SetLatencyUnit(unit)
+	return p, nil
+}
+
+// Parse decodes a JSON line and maps its keys to fields
+func (p *JSONParser) Parse(line string) (Log, error) {
+	d := json.NewDecoder(strings.NewReader(line))
+	d.UseNumber()
+	var obj map[string]interface{}
+	if err := d.Decode(&obj); err != nil {
+		return nil, errors.Wrap(err, "failed to parse log line")
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to parse log line: data after the JSON object")
+	}
+
+	l := jsonLog{fields: map[string]interface{}{}}
+	for name, path := range p.paths {
+		if v, ok := lookup(obj, path); ok && v != nil {
+			l.fields[name] = v
+		}
+	}
+	if err := l.parse(p.layout, p.latencyUnit); err != nil {
+		return nil, errors.Wrap(err, "failed to parse log line")
+	}
+	return &l, nil
+}
+
+// lookup finds the value at a dotted path. Keys may contain dots themselves,
+// the longest key matching the start of the path wins
+func lookup(obj map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := obj[path]; ok {
+		return v, true
+	}
+	for i := strings.LastIndexByte(path, '.'); i > 0; i = strings.LastIndexByte(path[:i], '.') {
+		child, ok := obj[path[:i]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := lookup(child, path[i+1:]); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// jsonLog is a log decoded from JSON. It keeps the mapped values as decoded and
+// the HTTPLog fields converted from them
+type jsonLog struct {
+	fields map[string]interface{}
+
+	time      time.Time
+	resource  string
+	host      string
+	user      string
+	method    string
+	protocol  string
+	referer   string
+	userAgent string
+	vhost     string
+	status    int
+	size      uint64
+}
+
+func (l *jsonLog) parse(layout string, latencyUnit time.Duration) error {
+	ts, ok := l.fields[FieldTimestamp]
+	if !ok {
+		return fmt.Errorf("no timestamp")
+	}
+	var err error
+	if l.time, err = parseTime(ts, layout); err != nil {
+		return err
+	}
+	if l.resource = l.text(FieldResource); l.resource == "" {
+		return fmt.Errorf("no resource")
+	}
+	if v, ok := l.fields[FieldStatus]; ok {
+		status, err := number(v)
+		if err != nil || status != math.Trunc(status) {
+			return fmt.Errorf("invalid status %v", v)
+		}
+		l.status = int(status)
+	}
+	if v, ok := l.fields[FieldSize]; ok {
+		size, err := number(v)
+		if err != nil || size < 0 || size != math.Trunc(size) {
+			return fmt.Errorf("invalid size %v", v)
+		}
+		l.size = uint64(size)
+	}
+	if v, ok := l.fields[FieldLatency]; ok {
+		latency, err := number(v)
+		if err != nil {
+			return fmt.Errorf("invalid latency %v", v)
+		}
+		l.fields[FieldLatency] = latency
+		if _, mapped := l.fields[FieldRequestTime]; !mapped {
+			l.fields[FieldRequestTime] = latency * latencyUnit.Seconds()
+		}
+	}
+	l.host = l.text(FieldHost)
+	l.user = l.text(FieldUser)
+	l.method = l.text(FieldMethod)
+	l.protocol = l.text(FieldProtocol)
+	l.referer = l.text(FieldReferer)
+	l.userAgent = l.text(FieldUserAgent)
+	l.vhost = l.text(FieldVirtualHost)
+	return nil
+}
+
+// text returns a field as a string, numbers as they were written
+func (l *jsonLog) text(name string) string {
+	switch v := l.fields[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// number converts a JSON number, or a string holding one, to a float64
+func number(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
+
+// parseTime parses a timestamp with a layout. Epochs may be numbers or strings
+func parseTime(v interface{}, layout string) (time.Time, error) {
+	var unit time.Duration
+	switch layout {
+	case LayoutUnix:
+		unit = time.Second
+	case LayoutUnixMs:
+		unit = time.Millisecond
+	case LayoutUnixUs:
+		unit = time.Microsecond
+	case LayoutUnixNs:
+		unit = time.Nanosecond
+	}
+	if unit != 0 {
+		s := fmt.Sprint(v)
+		// integers are kept exact, nanoseconds don't fit a float64
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(0, n*int64(unit)), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %v, expected %s", v, layout)
+		}
+		return time.Unix(0, int64(f*float64(unit))), nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid timestamp %v, expected a string", v)
+	}
+	if layout == LayoutRFC3339 {
+		layout = time.RFC3339Nano
+	}
+	t, err := time.Parse(layout, s)
+	return t, errors.Wrap(err, "invalid timestamp")
+}
+
+func (l *jsonLog) Timestamp() time.Time {
+	return l.time
+}
+
+func (l *jsonLog) Resource() string {
+	return l.resource
+}
+
+func (l *jsonLog) Host() string {
+	return l.host
+}
+
+func (l *jsonLog) User() string {
+	return l.user
+}
+
+func (l *jsonLog) Method() string {
+	return l.method
+}
+
+func (l *jsonLog) Protocol() string {
+	return l.protocol
+}
+
+func (l *jsonLog) Referer() string {
+	return l.referer
+}
+
+func (l *jsonLog) UserAgent() string {
+	return l.userAgent
+}
+
+func (l *jsonLog) Status() int {
+	return l.status
+}
+
+func (l *jsonLog) Size() uint64 {
+	return l.size
+}
+
+func (l *jsonLog) VirtualHost() string {
+	return l.vhost
+}
+
+// Field returns the latency as a float64 and the other mapped fields as they
+// were decoded, numbers as float64. The fields of HTTPLog are left to it
+func (l *jsonLog) Field(name string) (interface{}, bool) {
+	if httpFields[name] {
+		return nil, false
+	}
+	v, ok := l.fields[name]
+	if n, isNumber := v.(json.Number); isNumber {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return v, ok
+}
+
+// FieldNames lists the mapped fields beyond HTTPLog, with the request time when
+// it comes from the latency
+func (l *jsonLog) FieldNames() []string {
+	names := []string{}
+	for name := range l.fields {
+		if !httpFields[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
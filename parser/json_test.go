@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JSONParser(t *testing.T) {
+	p, err := NewJSONParser(map[string]string{
+		FieldTimestamp: "ts",
+		FieldResource:  "http.request.path",
+		FieldStatus:    "http.response.status_code",
+		FieldSize:      "http.response.bytes",
+		FieldHost:      "client.ip",
+		FieldUserAgent: "user_agent.original",
+		FieldLatency:   "duration_ms",
+		"trace_id":     "trace.id",
+	}, LayoutRFC3339)
+	assert.NoError(t, err)
+
+	l, err := p.Parse(`{"ts":"2018-11-10T12:00:00.250Z","http":{"request":{"path":"/api/user","method":"GET"},"response.status_code":503,"response":{"bytes":"1024"}},"client.ip":"10.0.0.1","user_agent":{"original":"curl/7.54.0"},"duration_ms":12.5,"trace":{"id":"abc"},"method":"POST"}`)
+	assert.NoError(t, err)
+	h := l.(HTTPLog)
+	assert.Equal(t, time.Date(2018, 11, 10, 12, 0, 0, 250e6, time.UTC), h.Timestamp().UTC())
+	assert.Equal(t, "/api/user", h.Resource())
+	assert.Equal(t, 503, h.Status())
+	assert.Equal(t, uint64(1024), h.Size())
+	assert.Equal(t, "10.0.0.1", h.Host())
+	assert.Equal(t, "curl/7.54.0", h.UserAgent())
+	// method isn't mapped, so the default key is used
+	assert.Equal(t, "POST", h.Method())
+	assert.Equal(t, "", h.Referer())
+
+	v, ok := Field(l, FieldLatency)
+	assert.True(t, ok)
+	assert.Equal(t, 12.5, v)
+	// seconds by default
+	v, ok = Field(l, FieldRequestTime)
+	assert.True(t, ok)
+	assert.Equal(t, 12.5, v)
+	v, ok = Field(l, "trace_id")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", v)
+	v, ok = Field(l, FieldStatus)
+	assert.True(t, ok)
+	assert.Equal(t, 503, v)
+	_, ok = Field(l, "span_id")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]interface{}{FieldLatency: 12.5, FieldRequestTime: 12.5, "trace_id": "abc"}, Fields(l))
+
+	// surrounding whitespace is fine
+	_, err = p.Parse(` {"ts":"2018-11-10T12:00:00Z","http":{"request":{"path":"/"}}}` + "\r\n")
+	assert.NoError(t, err)
+
+	for _, line := range []string{
+		`not json`,
+		`{"http":{"request":{"path":"/"}}}`,
+		`{"ts":"yesterday","http":{"request":{"path":"/"}}}`,
+		`{"ts":"2018-11-10T12:00:00Z"}`,
+		`{"ts":"2018-11-10T12:00:00Z","http":{"request":{"path":"/"},"response":{"status_code":"ok"}}}`,
+		`{"ts":"2018-11-10T12:00:00Z","http":{"request":{"path":"/"},"response":{"bytes":-1}}}`,
+		`{"ts":"2018-11-10T12:00:00Z","http":{"request":{"path":"/"}},"duration_ms":"slow"}`,
+		// a log cut short and the next one written over it
+		`{"ts":"2018-11-10T12:00:00Z","http":{"request":{"path":"/"}}}{"ts":"2018-11-10T12:00:01Z"`,
+		`{"ts":"2018-11-10T12:00:00Z","http":{"request":{"path":"/"}}} garbage`,
+		`{"ts":"2018-11-10T12:00:00Z","http":{"request":{"path":"/"}}}}`,
+	} {
+		_, err := p.Parse(line)
+		assert.Error(t, err, line)
+	}
+
+	_, err = NewJSONParser(map[string]string{FieldResource: ""}, "")
+	assert.Error(t, err)
+}
+
+func Test_JSONParser_layouts(t *testing.T) {
+	want := time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		layout string
+		ts     string
+		want   time.Time
+	}{
+		{"", `"2018-11-10T12:00:00Z"`, want},
+		{LayoutRFC3339, `"2018-11-10T14:00:00+02:00"`, want},
+		{LayoutUnix, `1541851200`, want},
+		{LayoutUnix, `1541851200.5`, want.Add(500 * time.Millisecond)},
+		{LayoutUnix, `"1541851200"`, want},
+		{LayoutUnixMs, `1541851200123`, want.Add(123 * time.Millisecond)},
+		{LayoutUnixUs, `1541851200123456`, want.Add(123456 * time.Microsecond)},
+		{LayoutUnixNs, `1541851200123456789`, want.Add(123456789)},
+		{"02/Jan/2006:15:04:05 -0700", `"10/Nov/2018:12:00:00 +0000"`, want},
+	}
+	for _, test := range tests {
+		t.Run(test.layout+" "+test.ts, func(t *testing.T) {
+			p, err := NewJSONParser(nil, test.layout)
+			assert.NoError(t, err)
+			l, err := p.Parse(`{"time":` + test.ts + `,"path":"/"}`)
+			assert.NoError(t, err)
+			assert.True(t, test.want.Equal(l.Timestamp()), l.Timestamp())
+		})
+	}
+
+	p, err := NewJSONParser(nil, LayoutUnixMs)
+	assert.NoError(t, err)
+	_, err = p.Parse(`{"time":"now","path":"/"}`)
+	assert.Error(t, err)
+	p, err = NewJSONParser(nil, "")
+	assert.NoError(t, err)
+	_, err = p.Parse(`{"time":1541851200,"path":"/"}`)
+	assert.Error(t, err)
+}
+
+func Test_ParseLogFormat_json(t *testing.T) {
+	p, err := ParseLogFormat("json")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultJSONFields, p.(*JSONParser).paths)
+
+	p, err = ParseLogFormat("json:timestamp=ts,resource=req.path,latency=took,layout=unix_ms")
+	assert.NoError(t, err)
+	l, err := p.Parse(`{"ts":1541851200000,"req":{"path":"/api"},"took":3}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "/api", l.Resource())
+	assert.True(t, time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC).Equal(l.Timestamp()))
+	v, ok := Field(l, FieldLatency)
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v)
+
+	p, err = ParseLogFormat("json:latency=took,latency_unit=ms")
+	assert.NoError(t, err)
+	l, err = p.Parse(`{"time":"2018-11-10T12:00:00Z","path":"/api","took":250}`)
+	assert.NoError(t, err)
+	v, ok = Field(l, FieldRequestTime)
+	assert.True(t, ok)
+	assert.Equal(t, 0.25, v)
+	// a request time of its own is kept
+	p, err = ParseLogFormat("json:latency=took,request_time=rt,latency_unit=ms")
+	assert.NoError(t, err)
+	l, err = p.Parse(`{"time":"2018-11-10T12:00:00Z","path":"/api","took":250,"rt":0.3}`)
+	assert.NoError(t, err)
+	v, ok = Field(l, FieldRequestTime)
+	assert.True(t, ok)
+	assert.Equal(t, 0.3, v)
+
+	_, err = ParseLogFormat("json:resource")
+	assert.Error(t, err)
+	_, err = ParseLogFormat("json:latency_unit=days")
+	assert.Error(t, err)
+}
@@ -13,6 +13,119 @@ type Log interface {
 	Resource() string
 }
 
+// HTTPLog is a log of an HTTP request that also carries the client and the
+// authenticated user, the request method, protocol, referer and user agent, the
+// response status and size, and the virtual host that served it, if logged
+type HTTPLog interface {
+	Log
+	Host() string
+	User() string
+	Method() string
+	Protocol() string
+	Referer() string
+	UserAgent() string
+	Status() int
+	Size() uint64
+	VirtualHost() string
+}
+
+// Fielder is implemented by logs that carry fields beyond HTTPLog, e.g. the
+// request time of a custom log format, so they can be looked up by name.
+// FieldNames lists them, in no particular order
+type Fielder interface {
+	Field(name string) (interface{}, bool)
+	FieldNames() []string
+}
+
+// Field names known to every HTTPLog
+const (
+	FieldTimestamp   = "timestamp"
+	FieldResource    = "resource"
+	FieldHost        = "host"
+	FieldUser        = "user"
+	FieldMethod      = "method"
+	FieldProtocol    = "protocol"
+	FieldStatus      = "status"
+	FieldSize        = "size"
+	FieldReferer     = "referer"
+	FieldUserAgent   = "user_agent"
+	FieldVirtualHost = "vhost"
+)
+
+// fields of HTTPLog, which Fielder implementations leave to it
+var httpFields = map[string]bool{
+	FieldTimestamp:   true,
+	FieldResource:    true,
+	FieldHost:        true,
+	FieldUser:        true,
+	FieldMethod:      true,
+	FieldProtocol:    true,
+	FieldStatus:      true,
+	FieldSize:        true,
+	FieldReferer:     true,
+	FieldUserAgent:   true,
+	FieldVirtualHost: true,
+}
+
+// Field looks a field of a log up by name, without type asserting to the log's
+// implementation. Values keep their type: time.Time for the timestamp, int for
+// the status, uint64 for the size and string for the others. It is false if the
+// log doesn't carry the field
+func Field(l Log, name string) (interface{}, bool) {
+	if f, ok := l.(Fielder); ok {
+		if v, ok := f.Field(name); ok {
+			return v, true
+		}
+	}
+	switch name {
+	case FieldTimestamp:
+		return l.Timestamp(), true
+	case FieldResource:
+		return l.Resource(), true
+	}
+	h, ok := l.(HTTPLog)
+	if !ok {
+		return nil, false
+	}
+	switch name {
+	case FieldHost:
+		return h.Host(), true
+	case FieldUser:
+		return h.User(), true
+	case FieldMethod:
+		return h.Method(), true
+	case FieldProtocol:
+		return h.Protocol(), true
+	case FieldStatus:
+		return h.Status(), true
+	case FieldSize:
+		return h.Size(), true
+	case FieldReferer:
+		return h.Referer(), true
+	case FieldUserAgent:
+		return h.UserAgent(), true
+	case FieldVirtualHost:
+		return h.VirtualHost(), true
+	}
+	return nil, false
+}
+
+// Fields returns the fields a log carries beyond HTTPLog by name, as Field looks
+// them up. It is nil for logs that aren't a Fielder
+func Fields(l Log) map[string]interface{} {
+	f, ok := l.(Fielder)
+	if !ok {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	for _, name := range f.FieldNames() {
+		if v, ok := f.Field(name); ok {
+			fields[name] = v
+		}
+	}
+	return fields
+}
+
 // LogParser is an interface that describes the behaviour expected to be exposed
 // by a parser used in the system
 type LogParser interface {
@@ -31,6 +144,42 @@ func (l *accessLog) Resource() string {
 	return l.RequestURI
 }
 
+func (l *accessLog) Host() string {
+	return l.Log.Host
+}
+
+func (l *accessLog) User() string {
+	return l.Log.User
+}
+
+func (l *accessLog) Protocol() string {
+	return l.Log.Protocol
+}
+
+func (l *accessLog) Referer() string {
+	return l.Log.Referer
+}
+
+func (l *accessLog) UserAgent() string {
+	return l.Log.UserAgent
+}
+
+func (l *accessLog) Method() string {
+	return l.Log.Method
+}
+
+func (l *accessLog) Size() uint64 {
+	return l.Log.Size
+}
+
+func (l *accessLog) Status() int {
+	return l.Log.Status
+}
+
+func (l *accessLog) VirtualHost() string {
+	return l.Log.VirtualHost
+}
+
 // AccessLogParser is an implementation of the LogParser that uses axslogparser
 // to process access log lines
 type AccessLogParser struct{}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AccessLogParser(t *testing.T) {
+	p := NewAccessLogParser()
+	l, err := p.Parse(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`)
+	assert.NoError(t, err)
+	h, ok := l.(HTTPLog)
+	assert.True(t, ok)
+	assert.Equal(t, "/apache_pb.gif", h.Resource())
+	assert.Equal(t, "127.0.0.1", h.Host())
+	assert.Equal(t, "frank", h.User())
+	assert.Equal(t, "GET", h.Method())
+	assert.Equal(t, "HTTP/1.0", h.Protocol())
+	assert.Equal(t, 200, h.Status())
+	assert.Equal(t, uint64(2326), h.Size())
+	assert.Equal(t, "http://www.example.com/start.html", h.Referer())
+	assert.Equal(t, "Mozilla/4.08", h.UserAgent())
+
+	_, err = p.Parse("not a log")
+	assert.Error(t, err)
+}
+
+type plainLog struct{}
+
+func (plainLog) Timestamp() time.Time { return time.Unix(0, 0) }
+func (plainLog) Resource() string     { return "/" }
+
+type fieldLog struct {
+	plainLog
+}
+
+func (fieldLog) Field(name string) (interface{}, bool) {
+	if name == "request_time" {
+		return 0.25, true
+	}
+	return nil, false
+}
+
+func (fieldLog) FieldNames() []string {
+	return []string{"request_time"}
+}
+
+func Test_Field(t *testing.T) {
+	l, err := NewAccessLogParser().Parse(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "-" "Mozilla/4.08"`)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{FieldTimestamp, time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600))},
+		{FieldResource, "/apache_pb.gif"},
+		{FieldHost, "127.0.0.1"},
+		{FieldUser, "frank"},
+		{FieldMethod, "GET"},
+		{FieldProtocol, "HTTP/1.0"},
+		{FieldStatus, 200},
+		{FieldSize, uint64(2326)},
+		{FieldReferer, "-"},
+		{FieldUserAgent, "Mozilla/4.08"},
+		{FieldVirtualHost, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, ok := Field(l, test.name)
+			assert.True(t, ok)
+			if ts, isTime := v.(time.Time); isTime {
+				assert.True(t, ts.Equal(test.value.(time.Time)))
+				return
+			}
+			assert.Equal(t, test.value, v)
+		})
+	}
+	_, ok := Field(l, "request_time")
+	assert.False(t, ok)
+
+	// plain logs only have a timestamp and a resource
+	v, ok := Field(plainLog{}, FieldResource)
+	assert.True(t, ok)
+	assert.Equal(t, "/", v)
+	_, ok = Field(plainLog{}, FieldStatus)
+	assert.False(t, ok)
+
+	v, ok = Field(fieldLog{}, "request_time")
+	assert.True(t, ok)
+	assert.Equal(t, 0.25, v)
+	v, ok = Field(fieldLog{}, FieldResource)
+	assert.True(t, ok)
+	assert.Equal(t, "/", v)
+
+	assert.Equal(t, map[string]interface{}{"request_time": 0.25}, Fields(fieldLog{}))
+	assert.Nil(t, Fields(plainLog{}))
+	assert.Nil(t, Fields(l))
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package reporter
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// cpu returns the CPU time used by the process so far
+func cpu(t *testing.T) time.Duration {
+	var u syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &u); err != nil {
+		t.Fatal(err)
+	}
+	return time.Duration(u.Utime.Nano() + u.Stime.Nano())
+}
+
+func Test_Start_idle(t *testing.T) {
+	r := NewReporter(10 * time.Second)
+	stop := r.Start(make(chan parser.Log))
+	defer stop()
+
+	before := cpu(t)
+	time.Sleep(time.Second)
+	used := cpu(t) - before
+	// a busy loop would use about a whole second
+	assert.True(t, used < 100*time.Millisecond, "idle reporter used %s of CPU in 1s", used)
+}
@@ -0,0 +1,207 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mihaichiorean/monidog/model"
+)
+
+// SectionReport is the stats of a section in a report
+type SectionReport struct {
+	Name          string      `json:"name"`
+	Hits          int         `json:"hits"`
+	Stats         model.Stats `json:"stats"`
+	UniqueClients uint64      `json:"unique_clients"`
+	UniqueURLs    uint64      `json:"unique_urls"`
+}
+
+// Report is the summary of the logs of a window. Reports are values: subscribers
+// each get their own copy and the reporter never changes them after delivery
+type Report struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Window is the size of the window, one of the reporter's windows
+	Window time.Duration `json:"window"`
+	// Sections are ordered by hits, then name
+	Sections   []SectionReport `json:"sections"`
+	HotSection string          `json:"hot_section"`
+	HotHits    int             `json:"hot_hits"`
+	Total      model.Stats     `json:"total"`
+	// Top lists the heavy hitters by model.TopKeys, highest first
+	Top           map[string][]model.KeyCount `json:"top"`
+	UniqueClients uint64                      `json:"unique_clients"`
+	UniqueURLs    uint64                      `json:"unique_urls"`
+	// distinct clients and urls of the windows reported within Horizon
+	Horizon        time.Duration `json:"horizon"`
+	HorizonClients uint64        `json:"horizon_clients"`
+	HorizonURLs    uint64        `json:"horizon_urls"`
+	// DroppedLate is how many logs were dropped as late so far
+	DroppedLate int `json:"dropped_late"`
+	// Trend compares the report with the previous window of the same size, nil
+	// for the first one
+	Trend *Trend `json:"trend,omitempty"`
+}
+
+// Copy returns a deep copy of the report
+func (rep Report) Copy() Report {
+	c := rep
+	c.Sections = make([]SectionReport, len(rep.Sections))
+	for i, s := range rep.Sections {
+		s.Stats = s.Stats.Copy()
+		c.Sections[i] = s
+	}
+	c.Total = rep.Total.Copy()
+	c.Top = make(map[string][]model.KeyCount, len(rep.Top))
+	for k, v := range rep.Top {
+		c.Top[k] = append([]model.KeyCount(nil), v...)
+	}
+	c.Trend = rep.Trend.Copy()
+	return c
+}
+
+// Section returns the stats of a section, if it had hits
+func (rep Report) Section(name string) (SectionReport, bool) {
+	for _, s := range rep.Sections {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return SectionReport{}, false
+}
+
+// report builds the report of the buckets in [start, end), and returns the
+// distinct clients and urls sketches of all its hits. A zero end means no end
+func (r *Reporter) report(start, end time.Time) (Report, *model.Uniques) {
+	return r.reportOf(merge(r.buckets, start, end), start, end)
+}
+
+// reportOf builds the report of the hits rolled up in b for [start, end)
+func (r *Reporter) reportOf(b *model.Bucket, start, end time.Time) (Report, *model.Uniques) {
+	rep := Report{
+		Start:       start,
+		End:         end,
+		Total:       model.NewStats(),
+		Top:         map[string][]model.KeyCount{},
+		DroppedLate: r.droppedLate,
+	}
+	if !end.IsZero() {
+		rep.Window = end.Sub(start)
+	}
+
+	stats := b.Stats()
+	tops := b.Tops()
+	uniques, total := b.Uniques(), b.TotalUniques()
+
+	for name, s := range stats {
+		sec := SectionReport{Name: name, Hits: s.Hits, Stats: s}
+		if u, ok := uniques[name]; ok {
+			sec.UniqueClients = u.Clients.Estimate()
+			sec.UniqueURLs = u.URLs.Estimate()
+		}
+		rep.Sections = append(rep.Sections, sec)
+		rep.Total.Merge(s)
+	}
+	sort.Slice(rep.Sections, func(i, j int) bool {
+		a, b := rep.Sections[i], rep.Sections[j]
+		if a.Hits != b.Hits {
+			return a.Hits > b.Hits
+		}
+		return a.Name < b.Name
+	})
+	if len(rep.Sections) > 0 {
+		rep.HotSection, rep.HotHits = rep.Sections[0].Name, rep.Sections[0].Hits
+	}
+	if r.topN > 0 {
+		for k, t := range tops {
+			rep.Top[k] = t.Top(r.topN)
+		}
+	}
+	rep.UniqueClients = total.Clients.Estimate()
+	rep.UniqueURLs = total.URLs.Estimate()
+	return rep, total
+}
+
+// PrintReport writes a report as text
+func PrintReport(w io.Writer, rep Report) {
+	if rep.Start.IsZero() {
+		fmt.Fprintln(w, "--------------------------------------")
+	} else {
+		fmt.Fprintf(w, "-------------------------------------- %s - %s (%s)\n", rep.Start.Format("15:04:05"), rep.End.Format("15:04:05"), rep.Window)
+	}
+	for _, s := range rep.Sections {
+		line := fmt.Sprintf("%s clients=%d urls=%d", formatStats(s.Stats), s.UniqueClients, s.UniqueURLs)
+		if rep.Trend != nil {
+			t, _ := rep.Trend.Section(s.Name)
+			line += " trend=" + formatSectionTrend(t)
+		}
+		fmt.Fprintln(w, s.Name, s.Hits, line)
+	}
+	if rep.Trend != nil {
+		if len(rep.Trend.New) > 0 {
+			fmt.Fprintln(w, "new sections: ", strings.Join(rep.Trend.New, " "))
+		}
+		if len(rep.Trend.Vanished) > 0 {
+			fmt.Fprintln(w, "vanished sections: ", strings.Join(rep.Trend.Vanished, " "))
+		}
+	}
+	if rep.Trend != nil && rep.HotSection != "" {
+		fmt.Fprintln(w, "highest hits section: ", rep.HotSection, rep.HotHits, "("+formatHotTrend(rep.Trend, rep.HotSection)+")")
+	} else {
+		fmt.Fprintln(w, "highest hits section: ", rep.HotSection, rep.HotHits)
+	}
+	fmt.Fprintln(w, "total: ", rep.Total.Hits, formatStats(rep.Total))
+	for _, k := range model.TopKeys {
+		if top, ok := rep.Top[k]; ok {
+			fmt.Fprintln(w, "top "+k+": ", formatTop(top))
+		}
+	}
+	if rep.Horizon > 0 {
+		fmt.Fprintf(w, "unique clients in last %s / %s: %d / %d\n", rep.Window, rep.Horizon, rep.UniqueClients, rep.HorizonClients)
+		fmt.Fprintf(w, "unique urls in last %s / %s: %d / %d\n", rep.Window, rep.Horizon, rep.UniqueURLs, rep.HorizonURLs)
+	}
+	if rep.DroppedLate > 0 {
+		fmt.Fprintln(w, "late logs dropped: ", rep.DroppedLate)
+	}
+}
+
+// Print writes the reports received on a subscription as text until it closes
+func Print(w io.Writer, reports <-chan Report) {
+	for rep := range reports {
+		PrintReport(w, rep)
+	}
+}
+
+// formatTop formats heavy hitters as key=count pairs, highest first
+func formatTop(counts []model.KeyCount) string {
+	parts := make([]string, 0, len(counts))
+	for _, c := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", c.Key, c.Count))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatStats formats the status, method and bytes breakdown of some hits, e.g.
+// "2xx=9 4xx=1 GET=10 bytes=5120 avg=512 errors=10.0%". Keys are sorted
+func formatStats(s model.Stats) string {
+	parts := []string{}
+	for _, m := range []map[string]int{s.Statuses, s.Methods} {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+		}
+	}
+	parts = append(parts,
+		fmt.Sprintf("bytes=%d", s.Bytes),
+		fmt.Sprintf("avg=%.0f", s.AvgBytes()),
+		fmt.Sprintf("errors=%.1f%%", s.ErrorPercent()),
+	)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,140 @@
+package reporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mihaichiorean/monidog/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_report(t *testing.T) {
+	r := NewReporter(10 * time.Second)
+	now := time.Now()
+	end := now.Truncate(10 * time.Second).Add(10 * time.Second)
+	start := end.Add(-10 * time.Second)
+	ts := start.Add(time.Second)
+	if ts.Before(now.Add(-10 * time.Second)) {
+		ts = now
+	}
+	for _, s := range []string{"/b", "/a", "/c", "/c", "/a"} {
+		r.record(s, ts, model.Hit{Status: 200, Client: "10.0.0.1", Path: s + "/x"})
+	}
+
+	rep, total := r.report(start, end)
+	names := []string{}
+	for _, s := range rep.Sections {
+		names = append(names, s.Name)
+	}
+	// by hits, then name
+	assert.Equal(t, []string{"/a", "/c", "/b"}, names)
+	assert.Equal(t, "/a", rep.HotSection)
+	assert.Equal(t, 2, rep.HotHits)
+	assert.Equal(t, 5, rep.Total.Hits)
+	assert.Equal(t, uint64(1), rep.UniqueClients)
+	assert.Equal(t, uint64(3), rep.UniqueURLs)
+	assert.Equal(t, uint64(3), total.URLs.Estimate())
+	assert.Equal(t, []model.KeyCount{{Key: "10.0.0.1", Count: 5}}, rep.Top[model.TopClients])
+	s, ok := rep.Section("/c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, s.Hits)
+
+	// copies don't share state
+	c := rep.Copy()
+	c.Sections[0].Stats.Add(model.Hit{Status: 500})
+	c.Top[model.TopClients][0].Count = 0
+	assert.Equal(t, 0, rep.Sections[0].Stats.Statuses["5xx"])
+	assert.Equal(t, 5, rep.Top[model.TopClients][0].Count)
+
+	// printing is deterministic
+	var a, b bytes.Buffer
+	PrintReport(&a, rep)
+	PrintReport(&b, c)
+	assert.Contains(t, a.String(), "/a 2 2xx=2 bytes=0 avg=0 errors=0.0% clients=1 urls=1\n/c 2")
+	assert.Contains(t, a.String(), "highest hits section:  /a 2")
+	assert.Contains(t, a.String(), "top clients:  10.0.0.1=5")
+}
+
+func Test_Subscribe(t *testing.T) {
+	r := NewReporter(10 * time.Second)
+	fast := r.Subscribe()
+	slow := r.Subscribe()
+
+	now := time.Now()
+	assert.Equal(t, 1, r.finalize(now))
+	rep := <-fast
+	assert.Equal(t, r.finalized, rep.End)
+	assert.Equal(t, 2*time.Minute, rep.Horizon)
+
+	// the slow subscriber doesn't block the others
+	for i := 1; i <= 10; i++ {
+		r.finalize(now.Add(time.Duration(i) * 10 * time.Second))
+		<-fast
+	}
+	assert.Equal(t, 1, r.Dropped())
+	assert.Len(t, slow, 10)
+
+	r.closeSubscribers()
+	_, ok := <-fast
+	assert.False(t, ok)
+}
+
+func Test_SetWindows(t *testing.T) {
+	r := NewReporter(10 * time.Second)
+	assert.Equal(t, []time.Duration{10 * time.Second}, r.Windows())
+	assert.Error(t, r.SetWindows())
+	assert.Error(t, r.SetWindows(500*time.Millisecond))
+	assert.Error(t, r.SetWindows(time.Second, 1500*time.Millisecond))
+	assert.Error(t, r.SetWindows(time.Minute, time.Second))
+	assert.NoError(t, r.SetWindows(10*time.Second, time.Minute, 5*time.Minute, time.Hour))
+	assert.Equal(t, []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute, time.Hour}, r.Windows())
+	assert.Equal(t, time.Second, r.bucketMS)
+
+	windows, err := ParseWindows("10s, 1m,5m,1h")
+	assert.NoError(t, err)
+	assert.Equal(t, r.Windows(), windows)
+	_, err = ParseWindows("10s,x")
+	assert.Error(t, err)
+}
+
+func Test_rollup(t *testing.T) {
+	r := NewReporter(time.Second)
+	assert.NoError(t, r.SetWindows(time.Second, 2*time.Second, 4*time.Second))
+	reports := r.Subscribe()
+
+	base := time.Now().Truncate(4 * time.Second).Add(4 * time.Second)
+	r.finalized = base
+	for i := 0; i < 4; i++ {
+		ts := base.Add(time.Duration(i)*time.Second + 500*time.Millisecond)
+		r.record("/a", ts, model.Hit{Status: 200, Client: "10.0.0.1"})
+		if i%2 == 0 {
+			r.record("/b", ts, model.Hit{Status: 500, Client: "10.0.0.2"})
+		}
+	}
+	assert.Equal(t, 4, r.finalize(base.Add(4*time.Second)))
+
+	got := map[time.Duration][]Report{}
+	for len(reports) > 0 {
+		rep := <-reports
+		got[rep.Window] = append(got[rep.Window], rep)
+	}
+	assert.Len(t, got[time.Second], 4)
+	if assert.Len(t, got[2*time.Second], 2) {
+		assert.Equal(t, base.Add(2*time.Second), got[2*time.Second][0].End)
+		assert.Equal(t, 3, got[2*time.Second][0].Total.Hits)
+	}
+	if assert.Len(t, got[4*time.Second], 1) {
+		rep := got[4*time.Second][0]
+		assert.Equal(t, base, rep.Start)
+		assert.Equal(t, 6, rep.Total.Hits)
+		assert.Equal(t, "/a", rep.HotSection)
+		assert.Equal(t, 2, rep.Total.Statuses["5xx"])
+		assert.Equal(t, uint64(2), rep.UniqueClients)
+		assert.Equal(t, []model.KeyCount{{Key: "10.0.0.1", Count: 4}, {Key: "10.0.0.2", Count: 2}}, rep.Top[model.TopClients])
+	}
+	// rolled up windows don't keep their parts
+	for _, ro := range r.rollups {
+		assert.Empty(t, ro.parts)
+	}
+}
@@ -3,7 +3,9 @@ package reporter
 import (
 	"fmt"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mihaichiorean/monidog/model"
@@ -11,12 +13,36 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Reporter is a struct that gathers stats for a time period of logs
+// Reporter is a struct that gathers stats for a time period of logs.
+// Stats are reported for consecutive windows aligned to the report window size. A
+// window is only reported once the watermark, allowedLateness before now, passed
+// its end; logs arriving after their window was reported are dropped as late.
+// Coarser windows are rolled up from the reported windows of the finer ones
+// instead of keeping their own buckets, see SetWindows
 type Reporter struct {
-	reportWindow time.Duration
-	bucketMS     time.Duration
-	buckets      []model.Bucket
-	in           chan parser.Log
+	reportWindow    time.Duration
+	bucketMS        time.Duration
+	allowedLateness time.Duration
+	buckets         []model.Bucket
+	sections        SectionExtractor
+	topN            int
+	uniqueHorizon   time.Duration
+	// distinct clients and paths of the reported windows within uniqueHorizon
+	pastUniques []windowUniques
+	rollups     []*rollup
+	// last report of every window, for trends
+	previous map[time.Duration]Report
+	// end of the last reported window
+	finalized   time.Time
+	droppedLate int
+	in          chan parser.Log
+	queries     chan chan snapshot
+
+	mu          sync.Mutex
+	subscribers []chan Report
+	dropped     int
+	// closed once the reporter stops, nil until it starts
+	stopped chan struct{}
 }
 
 // NewReporter is the factory function for a new reporter.
@@ -26,33 +52,132 @@ func NewReporter(window time.Duration) *Reporter {
 	// opinionated option to choose a 10 bucket granularity/accuracy level. Should be customizable
 	bucketSize := (window / 10)
 	r := Reporter{
-		reportWindow: window,
-		bucketMS:     bucketSize,
-		buckets:      make([]model.Bucket, 0, 10),
-		in:           make(chan parser.Log),
+		reportWindow:  window,
+		bucketMS:      bucketSize,
+		buckets:       make([]model.Bucket, 0, 10),
+		sections:      LastSlash(),
+		topN:          5,
+		uniqueHorizon: 2 * time.Minute,
+		previous:      map[time.Duration]Report{},
+		in:            make(chan parser.Log),
+		queries:       make(chan chan snapshot),
 	}
 	return &r
 }
 
+// MinWindow is the shortest report window, split in buckets of 100ms
+const MinWindow = time.Second
+
+// rollup is a coarser report window made of the reported windows of the finer one
+type rollup struct {
+	window time.Duration
+	parts  []model.Bucket
+}
+
+// SetWindows sets the report windows, e.g. 10s, 1m, 5m and 1h. The first one is
+// split in 10 buckets and every other one is rolled up from the reports of the
+// previous one, so each must be a multiple of the previous. Must be called
+// before Start. Windows are aligned to their size, so the first report of a
+// coarse window after starting only covers the part since then
+func (r *Reporter) SetWindows(windows ...time.Duration) error {
+	if len(windows) == 0 {
+		return fmt.Errorf("at least one report window is required")
+	}
+	if windows[0] < MinWindow {
+		return fmt.Errorf("report window %s is shorter than %s", windows[0], MinWindow)
+	}
+	for i := 1; i < len(windows); i++ {
+		if windows[i] <= windows[i-1] || windows[i]%windows[i-1] != 0 {
+			return fmt.Errorf("report window %s is not a multiple of %s", windows[i], windows[i-1])
+		}
+	}
+	r.reportWindow = windows[0]
+	r.bucketMS = windows[0] / 10
+	r.buckets = make([]model.Bucket, 0, 10)
+	r.rollups = nil
+	for _, w := range windows[1:] {
+		r.rollups = append(r.rollups, &rollup{window: w})
+	}
+	return nil
+}
+
+// Windows returns the report windows, finest first
+func (r *Reporter) Windows() []time.Duration {
+	windows := []time.Duration{r.reportWindow}
+	for _, ro := range r.rollups {
+		windows = append(windows, ro.window)
+	}
+	return windows
+}
+
+// ParseWindows parses a comma separated list of windows, e.g. 10s,1m,5m,1h
+func ParseWindows(spec string) ([]time.Duration, error) {
+	windows := []time.Duration{}
+	for _, w := range strings.Split(spec, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(w))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid report window %q", w)
+		}
+		windows = append(windows, d)
+	}
+	return windows, nil
+}
+
+// SetSectionExtractor changes how sections are derived from logs. See SectionExtractor
+func (r *Reporter) SetSectionExtractor(e SectionExtractor) {
+	r.sections = e
+}
+
+// SetTopK sets how many of the top clients, user agents, referrers and paths are
+// reported for each window. 0 turns them off
+func (r *Reporter) SetTopK(n int) {
+	r.topN = n
+}
+
+// SetUniqueHorizon sets the longer period distinct clients and paths are
+// estimated over, besides the report window. It is rounded to report windows
+func (r *Reporter) SetUniqueHorizon(d time.Duration) {
+	r.uniqueHorizon = d
+}
+
+// SetAllowedLateness sets how late logs may arrive and still be counted in their
+// window. Reports are delayed by as much
+func (r *Reporter) SetAllowedLateness(d time.Duration) {
+	r.allowedLateness = d
+}
+
+// watermark is the time up to which windows are complete
+func (r *Reporter) watermark(now time.Time) time.Time {
+	return now.Add(-(r.allowedLateness))
+}
+
+// cutoff returns the time before which logs are not counted anymore
+func (r *Reporter) cutoff(now time.Time) time.Time {
+	cutoff := r.watermark(now).Add(-(r.reportWindow))
+	if r.finalized.After(cutoff) {
+		cutoff = r.finalized
+	}
+	return cutoff
+}
+
 // clear removes all old counts from the bucketlist
 func (r *Reporter) clear() {
-	ts := time.Now()
-	cutoff := ts.Add(-(r.bucketMS * 9))
+	cutoff := r.cutoff(time.Now()).Truncate(r.bucketMS)
 
 	buckets := []model.Bucket{}
 	for _, b := range r.buckets {
-		if b.Ts.Unix() >= cutoff.Unix() {
+		if !b.Ts.Before(cutoff) {
 			buckets = append(buckets, b)
 		}
 	}
 	r.buckets = buckets
 }
 
-// incSection increments a section's counts
-func (r *Reporter) incSection(s string, ts time.Time) int {
-	cutoff := time.Now().Add(-(r.reportWindow))
-	if ts.Before(cutoff) {
+// record counts a hit of a section and its breakdown
+func (r *Reporter) record(s string, ts time.Time, h model.Hit) int {
+	if ts.Before(r.cutoff(time.Now())) {
 		// this log is too old. discard
+		r.droppedLate++
 		return 0
 	}
 	r.clear()
@@ -61,13 +186,13 @@ func (r *Reporter) incSection(s string, ts time.Time) int {
 	for i := l - 1; i >= 0; i-- {
 		b := r.buckets[i]
 		if b.Ts == bucketTS {
-			return b.Inc(s)
+			return b.Add(s, h)
 		}
 	}
 
 	// new bucket
 	bucket := model.NewBucket(bucketTS)
-	bucket.Inc(s)
+	bucket.Add(s, h)
 	r.buckets = append(r.buckets, *bucket)
 	return 1
 }
@@ -93,80 +218,243 @@ func parseSection(s string) (string, error) {
 	return str, nil
 }
 
-// seectionStats returns a map of sections and the number of hits they got in the previous window
-func (r *Reporter) sectionStats() map[string]int {
-	totals := map[string]int{}
-	for _, b := range r.buckets {
-		c := b.Counters()
-		for k, v := range c {
-			if _, ok := totals[k]; !ok {
-				totals[k] = 0
-			}
-			totals[k] += v
+// inWindow tells if a bucket is in [start, end). A zero end means no end
+func inWindow(b model.Bucket, start, end time.Time) bool {
+	return !b.Ts.Before(start) && (end.IsZero() || b.Ts.Before(end))
+}
+
+// merge rolls the buckets in [start, end) up into a single one. A zero end
+// means no end
+func merge(buckets []model.Bucket, start, end time.Time) *model.Bucket {
+	m := model.NewBucket(start)
+	for i := range buckets {
+		if inWindow(buckets[i], start, end) {
+			m.Merge(&buckets[i])
 		}
 	}
-	return totals
+	return m
+}
+
+// windowUniques are the distinct clients and paths of a reported window
+type windowUniques struct {
+	end     time.Time
+	uniques *model.Uniques
 }
 
-// hotSection returns the section with the most hits in the previous interval of logs
-func (r *Reporter) hotSection() (string, int) {
-	totals := r.sectionStats()
-	max := 0
-	section := ""
-	for k, v := range totals {
-		if v > max {
-			max = v
-			section = k
+// horizonUniques remembers the distinct clients and paths of a reported window
+// and returns the ones of the windows within the unique horizon before end
+func (r *Reporter) horizonUniques(end time.Time, u *model.Uniques) *model.Uniques {
+	r.pastUniques = append(r.pastUniques, windowUniques{end: end, uniques: u})
+	cutoff := end.Add(-(r.uniqueHorizon))
+	past := []windowUniques{}
+	total := model.NewUniques(model.TotalHLLPrecision)
+	for _, w := range r.pastUniques {
+		if w.end.After(cutoff) {
+			past = append(past, w)
+			total.Merge(w.uniques)
 		}
 	}
-
-	return section, max
+	r.pastUniques = past
+	return total
 }
 
 func (r *Reporter) add(l parser.Log) error {
-	sec, err := parseSection(l.Resource())
+	sec, err := r.sections.Section(l)
 	if err != nil {
 		return errors.Wrap(err, "Add to reporter failed")
 	}
-	r.incSection(sec, l.Timestamp())
+	h := model.Hit{Path: l.Resource()}
+	if hl, ok := l.(parser.HTTPLog); ok {
+		h.Status = hl.Status()
+		h.Method = hl.Method()
+		h.Bytes = hl.Size()
+		h.Client = hl.Host()
+		h.UserAgent = hl.UserAgent()
+		h.Referrer = hl.Referer()
+	}
+	r.record(sec, l.Timestamp(), h)
 	return nil
 }
 
-// Start triggers the async flow of printing stats. returns a function used to stop the reporter from printing
+// Start triggers the async flow of reporting stats to subscribers. returns a
+// function used to stop the reporter, which waits for it to stop.
+// Once started, the reporter's state is only touched by its own goroutine: use
+// Snapshot and Latest to read it, and call the setters before starting
 func (r *Reporter) Start(in <-chan parser.Log) func() {
 	done := make(chan struct{})
+	stopped := make(chan struct{})
+	r.mu.Lock()
+	r.stopped = stopped
+	r.mu.Unlock()
+
+	var once sync.Once
 	cancel := func() {
-		done <- struct{}{}
+		once.Do(func() { close(done) })
+		<-stopped
 	}
 	go func() {
-		t := time.NewTicker(r.reportWindow)
+		defer close(stopped)
+		// check for windows the watermark passed every bucket
+		t := time.NewTicker(r.bucketMS)
+		defer t.Stop()
 		for {
 			select {
-			case log := <-in:
+			case log, ok := <-in:
+				if !ok {
+					// keep reporting what was received
+					in = nil
+					continue
+				}
 				r.add(log)
 			case <-t.C:
+				r.finalize(time.Now())
 				r.clear()
-				r.PrintSectionStats()
+			case q := <-r.queries:
+				q <- r.snapshot()
 			case <-done:
+				r.closeSubscribers()
 				return
-			default:
 			}
 		}
 	}()
 	return cancel
 }
 
-// PrintSectionStats shows the section with the most hits
+// snapshot is the state of the reporter
+type snapshot struct {
+	current Report
+	latest  []Report
+}
+
+func (r *Reporter) snapshot() snapshot {
+	cur, _ := r.report(r.cutoff(time.Now()).Truncate(r.bucketMS), time.Time{})
+	s := snapshot{current: cur}
+	for _, w := range r.Windows() {
+		if rep, ok := r.previous[w]; ok {
+			s.latest = append(s.latest, rep.Copy())
+		}
+	}
+	return s
+}
+
+// query returns the state of the reporter from its goroutine, or directly if it
+// is not running
+func (r *Reporter) query() snapshot {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.mu.Unlock()
+	if stopped != nil {
+		q := make(chan snapshot)
+		select {
+		case r.queries <- q:
+			return <-q
+		case <-stopped:
+		}
+	}
+	return r.snapshot()
+}
+
+// Snapshot returns the stats of the logs received in the last report window up
+// to now, including the ones not reported yet. It is safe to call while the
+// reporter runs
+func (r *Reporter) Snapshot() Report {
+	return r.query().current
+}
+
+// Latest returns the last report of every window, finest first. Windows not
+// reported yet are left out. It is safe to call while the reporter runs
+func (r *Reporter) Latest() []Report {
+	return r.query().latest
+}
+
+// finalize reports every window the watermark passed since the last report
+// and returns how many were reported
+func (r *Reporter) finalize(now time.Time) int {
+	last := r.watermark(now).Truncate(r.reportWindow)
+	next := last
+	if !r.finalized.IsZero() {
+		next = r.finalized.Add(r.reportWindow)
+	}
+	n := 0
+	for ; !next.After(last); next = next.Add(r.reportWindow) {
+		start := next.Add(-(r.reportWindow))
+		m := merge(r.buckets, start, next)
+		rep, total := r.reportOf(m, start, next)
+		long := r.horizonUniques(next, total)
+		rep.Horizon = r.uniqueHorizon
+		rep.HorizonClients = long.Clients.Estimate()
+		rep.HorizonURLs = long.URLs.Estimate()
+		r.track(&rep)
+		r.publish(rep)
+		r.rollup(0, m, next)
+		r.finalized = next
+		n++
+	}
+	return n
+}
+
+// rollup adds a reported window ending at end to the ith coarser window, and
+// reports that one too, and so on up, if it ends there as well
+func (r *Reporter) rollup(i int, b *model.Bucket, end time.Time) {
+	if i >= len(r.rollups) {
+		return
+	}
+	ro := r.rollups[i]
+	ro.parts = append(ro.parts, *b)
+	if !end.Truncate(ro.window).Equal(end) {
+		return
+	}
+	start := end.Add(-(ro.window))
+	m := merge(ro.parts, start, end)
+	rep, _ := r.reportOf(m, start, end)
+	r.track(&rep)
+	r.publish(rep)
+	ro.parts = nil
+	r.rollup(i+1, m, end)
+}
+
+// Subscribe returns a channel the report of every window is sent on. Reports
+// are dropped for subscribers that fall behind by more than a few windows, see
+// Dropped. The channel is closed when the reporter stops
+func (r *Reporter) Subscribe() <-chan Report {
+	ch := make(chan Report, 10)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, ch)
+	return ch
+}
+
+// Dropped returns how many reports were dropped for slow subscribers
+func (r *Reporter) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// publish sends a copy of the report to every subscriber without blocking
+func (r *Reporter) publish(rep Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.subscribers {
+		select {
+		case s <- rep.Copy():
+		default:
+			r.dropped++
+		}
+	}
+}
+
+// closeSubscribers closes the subscriptions once the reporter stops
+func (r *Reporter) closeSubscribers() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.subscribers {
+		close(s)
+	}
+	r.subscribers = nil
+}
+
+// PrintSectionStats shows the stats of all the logs kept
 func (r *Reporter) PrintSectionStats() {
-	fmt.Println("--------------------------------------")
-	t := r.sectionStats()
-	i := 0
-	for s, v := range t {
-		// clearing line by printing empty space over it
-		// print section stats on thee ith line
-		fmt.Println(s, v)
-		i++
-	}
-	sec, count := r.hotSection()
-	fmt.Println("highest hits section: ", sec, count)
+	PrintReport(os.Stdout, r.Snapshot())
 }
@@ -1,11 +1,15 @@
 package reporter
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/mihaichiorean/monidog/mocks"
+	"github.com/mihaichiorean/monidog/model"
+	"github.com/mihaichiorean/monidog/parser"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,31 +35,234 @@ func Test_NewReporter(t *testing.T) {
 	assert.Equal(t, 1000*time.Millisecond, r.bucketMS)
 }
 
+// reportOf finalizes the window of ts on a new reporter and returns the report
+// its subscribers receive
+func reportOf(t *testing.T, r *Reporter, ts time.Time) Report {
+	reports := r.Subscribe()
+	assert.Equal(t, 1, r.finalize(ts.Add(r.reportWindow)))
+	return <-reports
+}
+
 func Test_hotSection(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
-	ts := time.Now()
+	start := time.Now().Truncate(10 * time.Second)
 	r := NewReporter(10 * time.Second)
 	for i := 1000; i > 0; i-- {
-		offset := i * 20
-		offsetMS := time.Duration(offset) * time.Millisecond
+		ts := start.Add(time.Duration(i%500) * 20 * time.Millisecond)
 		l := mocks.NewMockLog(ctrl)
-		l.EXPECT().Timestamp().Return(ts.Add(-offsetMS))
-		l.EXPECT().Resource().Return("/pages/all/create")
+		l.EXPECT().Timestamp().Return(ts)
+		l.EXPECT().Resource().Return("/pages/all/create").AnyTimes()
 		r.add(l)
 		if i%2 == 0 {
 			l := mocks.NewMockLog(ctrl)
-			l.EXPECT().Timestamp().Return(ts.Add(-offsetMS))
-			l.EXPECT().Resource().Return("/pages/half/create")
+			l.EXPECT().Timestamp().Return(ts)
+			l.EXPECT().Resource().Return("/pages/half/create").AnyTimes()
 			r.add(l)
 		}
 		if i%4 == 0 {
 			l := mocks.NewMockLog(ctrl)
-			l.EXPECT().Timestamp().Return(ts.Add(-offsetMS))
-			l.EXPECT().Resource().Return("/pages/quarter/create")
+			l.EXPECT().Timestamp().Return(ts)
+			l.EXPECT().Resource().Return("/pages/quarter/create").AnyTimes()
 			r.add(l)
 		}
 	}
-	section, _ := r.hotSection()
-	assert.Equal(t, "/pages/all", section)
+	rep := reportOf(t, r, start)
+	assert.Equal(t, "/pages/all", rep.HotSection)
+	assert.Equal(t, 1000, rep.HotHits)
+	assert.Equal(t, 1750, rep.Total.Hits)
+}
+
+func Test_allowedLateness(t *testing.T) {
+	r := NewReporter(10 * time.Second)
+	r.SetAllowedLateness(5 * time.Second)
+	reports := r.Subscribe()
+	now := time.Now()
+
+	// nothing to report until the watermark passes the end of a window
+	assert.Equal(t, 1, r.finalize(now))
+	<-reports
+	last := r.finalized
+	assert.Equal(t, 0, r.finalize(last.Add(5*time.Second-time.Millisecond)))
+
+	// late but within tolerance: lands in the open window
+	assert.Equal(t, 1, r.record("/pages", last.Add(time.Second), model.Hit{}))
+	assert.Equal(t, 0, r.droppedLate)
+
+	// older than the last reported window
+	assert.Equal(t, 0, r.record("/pages", last.Add(-time.Millisecond), model.Hit{}))
+	assert.Equal(t, 1, r.droppedLate)
+
+	// two windows passed at once
+	assert.Equal(t, 2, r.finalize(last.Add(25*time.Second)))
+	assert.Equal(t, last.Add(20*time.Second), r.finalized)
+	rep := <-reports
+	assert.Equal(t, last, rep.Start)
+	assert.Equal(t, 1, rep.Total.Hits)
+	assert.Equal(t, "/pages", rep.HotSection)
+	assert.Equal(t, 1, rep.DroppedLate)
+	rep = <-reports
+	assert.Empty(t, rep.Sections)
+}
+
+func Test_breakdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ts := time.Now()
+	r := NewReporter(10 * time.Second)
+	for _, h := range []struct {
+		resource string
+		method   string
+		status   int
+		size     uint64
+	}{
+		{"/users/1", "GET", 200, 100},
+		{"/users/2", "POST", 201, 300},
+		{"/orders/1", "GET", 500, 0},
+		{"/orders/2", "GET", 404, 20},
+	} {
+		l := mocks.NewMockHTTPLog(ctrl)
+		l.EXPECT().Timestamp().Return(ts)
+		l.EXPECT().Resource().Return(h.resource).AnyTimes()
+		l.EXPECT().Host().Return("10.0.0.1")
+		l.EXPECT().UserAgent().Return("curl/7.54.0")
+		l.EXPECT().Referer().Return("-")
+		l.EXPECT().Method().Return(h.method)
+		l.EXPECT().Status().Return(h.status)
+		l.EXPECT().Size().Return(h.size)
+		assert.NoError(t, r.add(l))
+	}
+	plain := mocks.NewMockLog(ctrl)
+	plain.EXPECT().Timestamp().Return(ts)
+	plain.EXPECT().Resource().Return("/users/3").AnyTimes()
+	assert.NoError(t, r.add(plain))
+
+	rep := reportOf(t, r, ts)
+	users, _ := rep.Section("/users")
+	orders, _ := rep.Section("/orders")
+	assert.Equal(t, 3, users.Hits)
+	assert.Equal(t, "2xx=2 GET=1 POST=1 bytes=400 avg=133 errors=0.0%", formatStats(users.Stats))
+	assert.Equal(t, "4xx=1 5xx=1 GET=2 bytes=20 avg=10 errors=100.0%", formatStats(orders.Stats))
+	assert.Equal(t, 5, rep.Total.Hits)
+	assert.Equal(t, uint64(420), rep.Total.Bytes)
+}
+
+func Test_windowTops(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	start := time.Now().Truncate(10 * time.Second)
+	r := NewReporter(10 * time.Second)
+	for i := 0; i < 50; i++ {
+		l := mocks.NewMockHTTPLog(ctrl)
+		// a scraper hammering one endpoint among many clients
+		client, resource := fmt.Sprintf("10.0.%d.%d", i/256, i%256), fmt.Sprintf("/pages/%d", i)
+		if i%2 == 0 {
+			client, resource = "6.6.6.6", "/search?q=x"
+		}
+		l.EXPECT().Timestamp().Return(start.Add(time.Duration(i) * 100 * time.Millisecond))
+		l.EXPECT().Resource().Return(resource).AnyTimes()
+		l.EXPECT().Host().Return(client)
+		l.EXPECT().UserAgent().Return("bot")
+		l.EXPECT().Referer().Return("")
+		l.EXPECT().Method().Return("GET")
+		l.EXPECT().Status().Return(200)
+		l.EXPECT().Size().Return(uint64(10))
+		assert.NoError(t, r.add(l))
+	}
+
+	rep := reportOf(t, r, start)
+	assert.Equal(t, model.KeyCount{Key: "6.6.6.6", Count: 25}, rep.Top[model.TopClients][0])
+	assert.Equal(t, model.KeyCount{Key: "/search?q=x", Count: 25}, rep.Top[model.TopPaths][0])
+	assert.Equal(t, []model.KeyCount{{Key: "bot", Count: 50}}, rep.Top[model.TopUserAgents])
+	_, ok := rep.Top[model.TopReferrers]
+	assert.False(t, ok)
+	assert.Equal(t, "6.6.6.6=25 10.0.0.1=1", formatTop(rep.Top[model.TopClients][:2]))
+	// the top 5 only
+	assert.Len(t, rep.Top[model.TopClients], 5)
+}
+
+func Test_uniques(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ts := time.Now()
+	r := NewReporter(10 * time.Second)
+	r.SetUniqueHorizon(30 * time.Second)
+	for i := 0; i < 20; i++ {
+		l := mocks.NewMockHTTPLog(ctrl)
+		l.EXPECT().Timestamp().Return(ts)
+		l.EXPECT().Resource().Return(fmt.Sprintf("/pages/%d", i%5)).AnyTimes()
+		l.EXPECT().Host().Return(fmt.Sprintf("10.0.0.%d", i%2))
+		l.EXPECT().UserAgent().Return("")
+		l.EXPECT().Referer().Return("")
+		l.EXPECT().Method().Return("GET")
+		l.EXPECT().Status().Return(200)
+		l.EXPECT().Size().Return(uint64(0))
+		assert.NoError(t, r.add(l))
+	}
+	// windows reported before, the first older than the horizon
+	end := ts.Truncate(10 * time.Second).Add(10 * time.Second)
+	other := model.NewUniques(model.TotalHLLPrecision)
+	other.Add(model.Hit{Client: "10.0.0.9"})
+	r.horizonUniques(end.Add(-30*time.Second), other)
+	r.horizonUniques(end.Add(-20*time.Second), other)
+
+	rep := reportOf(t, r, ts)
+	assert.Equal(t, end, rep.End)
+	assert.Equal(t, uint64(2), rep.UniqueClients)
+	assert.Equal(t, uint64(5), rep.UniqueURLs)
+	pages, _ := rep.Section("/pages")
+	assert.Equal(t, uint64(5), pages.UniqueURLs)
+	assert.Equal(t, uint64(3), rep.HorizonClients)
+	assert.Len(t, r.pastUniques, 2)
+}
+
+func Test_Start(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	r := NewReporter(200 * time.Millisecond)
+	reports := r.Subscribe()
+	in := make(chan parser.Log)
+	stop := r.Start(in)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			l := mocks.NewMockLog(ctrl)
+			l.EXPECT().Timestamp().Return(time.Now())
+			l.EXPECT().Resource().Return("/pages/create").AnyTimes()
+			in <- l
+		}
+		// a closed input doesn't stop reporting
+		close(in)
+	}()
+	// read the state while logs come in
+	for i := 0; i < 50; i++ {
+		r.Snapshot()
+		r.Latest()
+	}
+	wg.Wait()
+
+	// every log is reported once
+	hits := 0
+	timeout := time.After(5 * time.Second)
+	for hits < 50 {
+		select {
+		case rep := <-reports:
+			assert.Equal(t, 200*time.Millisecond, rep.Window)
+			hits += rep.Total.Hits
+		case <-timeout:
+			t.Fatalf("reported %d hits, want 50", hits)
+		}
+	}
+	assert.Equal(t, 50, hits)
+	assert.NotEmpty(t, r.Latest())
+
+	stop()
+	stop()
+	for range reports {
+	}
+	// stopped reporters are read directly
+	assert.NotEmpty(t, r.Latest())
 }
@@ -0,0 +1,45 @@
+// Package reportertest builds reports for the tests of report subscribers
+package reportertest
+
+import (
+	"time"
+
+	"github.com/mihaichiorean/monidog/model"
+	"github.com/mihaichiorean/monidog/reporter"
+)
+
+// Start is when test reports start unless they need another time
+var Start = time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+
+// Section builds the report of a section from its responses by status class,
+// e.g. {"2xx": 3, "5xx": 1}. Every request is a GET of 100 bytes
+func Section(name string, statuses map[string]int) reporter.SectionReport {
+	s := model.NewStats()
+	for class, n := range statuses {
+		s.Statuses[class] = n
+		s.Methods["GET"] += n
+		s.Hits += n
+		s.Bytes += uint64(n * 100)
+	}
+	return reporter.SectionReport{Name: name, Hits: s.Hits, Stats: s}
+}
+
+// Report builds the report of the window from start with the sections in the
+// given order, their total and the hot section
+func Report(start time.Time, window time.Duration, sections ...reporter.SectionReport) reporter.Report {
+	rep := reporter.Report{
+		Start:    start,
+		End:      start.Add(window),
+		Window:   window,
+		Sections: sections,
+		Total:    model.NewStats(),
+	}
+	for _, s := range sections {
+		rep.Total.Merge(s.Stats)
+		if s.Hits > rep.HotHits {
+			rep.HotSection = s.Name
+			rep.HotHits = s.Hits
+		}
+	}
+	return rep
+}
@@ -0,0 +1,170 @@
+package reporter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mihaichiorean/monidog/parser"
+	"github.com/pkg/errors"
+)
+
+// OtherSection is where logs that a regex extractor does not match are counted
+const OtherSection = "(other)"
+
+// SectionExtractor decides which section the hit of a log is counted under
+type SectionExtractor interface {
+	Section(l parser.Log) (string, error)
+}
+
+// SectionFunc adapts a function to a SectionExtractor
+type SectionFunc func(l parser.Log) (string, error)
+
+// Section implements SectionExtractor
+func (f SectionFunc) Section(l parser.Log) (string, error) {
+	return f(l)
+}
+
+// path returns the path of a resource without its query and trailing slash
+func path(resource string) (string, error) {
+	u, err := url.Parse(resource)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to parse uri: %s", resource)
+	}
+	if len(u.Path) > 1 {
+		return strings.TrimRight(u.Path, "/"), nil
+	}
+	return u.Path, nil
+}
+
+// LastSlash is the default extractor: the section is everything before the last
+// slash of the path, so /pages/create is counted under /pages
+func LastSlash() SectionExtractor {
+	return SectionFunc(func(l parser.Log) (string, error) {
+		return parseSection(l.Resource())
+	})
+}
+
+// Segments uses the first n segments of the path as the section, so with n = 2
+// /api/v1/users/123 is counted under /api/v1
+func Segments(n int) SectionExtractor {
+	return SectionFunc(func(l parser.Log) (string, error) {
+		p, err := path(l.Resource())
+		if err != nil {
+			return "", err
+		}
+		segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		if len(segments) > n {
+			segments = segments[:n]
+		}
+		return "/" + strings.Join(segments, "/"), nil
+	})
+}
+
+// Regex matches the path against a regular expression and uses its first capture
+// group as the section, or the whole match if it has no groups. Paths that don't
+// match are counted under OtherSection
+func Regex(expr string) (SectionExtractor, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid section regex %q", expr)
+	}
+	return SectionFunc(func(l parser.Log) (string, error) {
+		p, err := path(l.Resource())
+		if err != nil {
+			return "", err
+		}
+		m := re.FindStringSubmatch(p)
+		if m == nil {
+			return OtherSection, nil
+		}
+		if len(m) > 1 {
+			return m[1], nil
+		}
+		return m[0], nil
+	}), nil
+}
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hashSegment = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	idSegment   = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// RouteTemplate uses the whole path as the section, with the segments that look
+// like identifiers collapsed: numbers become {id}, UUIDs {uuid} and long hex
+// strings {hash}, so /users/123/posts is counted under /users/{id}/posts
+func RouteTemplate() SectionExtractor {
+	return SectionFunc(func(l parser.Log) (string, error) {
+		p, err := path(l.Resource())
+		if err != nil {
+			return "", err
+		}
+		segments := strings.Split(p, "/")
+		for i, s := range segments {
+			switch {
+			case idSegment.MatchString(s):
+				segments[i] = "{id}"
+			case uuidSegment.MatchString(s):
+				segments[i] = "{uuid}"
+			case hashSegment.MatchString(s):
+				segments[i] = "{hash}"
+			}
+		}
+		return strings.Join(segments, "/"), nil
+	})
+}
+
+// HostPrefixed prefixes the sections of another extractor with the virtual host
+// of the request, so the same path on different vhosts is counted separately.
+// Logs without a virtual host keep the plain section
+func HostPrefixed(e SectionExtractor) SectionExtractor {
+	return SectionFunc(func(l parser.Log) (string, error) {
+		s, err := e.Section(l)
+		if err != nil {
+			return "", err
+		}
+		h, ok := l.(parser.HTTPLog)
+		if !ok || h.VirtualHost() == "" {
+			return s, nil
+		}
+		return h.VirtualHost() + s, nil
+	})
+}
+
+// ParseSectionExtractor builds an extractor from a spec, as given on the command line:
+//
+//	last-slash      everything before the last slash (default)
+//	segments:N      the first N path segments
+//	regex:EXPR      the first capture group of EXPR
+//	template        the path with IDs, UUIDs and hashes collapsed
+//	host:SPEC       any of the above prefixed with the virtual host
+func ParseSectionExtractor(spec string) (SectionExtractor, error) {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, arg = spec[:i], spec[i+1:]
+	}
+	switch kind {
+	case "", "last-slash":
+		return LastSlash(), nil
+	case "segments":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid section spec %q. segments needs a positive number", spec)
+		}
+		return Segments(n), nil
+	case "regex":
+		return Regex(arg)
+	case "template":
+		return RouteTemplate(), nil
+	case "host":
+		inner, err := ParseSectionExtractor(arg)
+		if err != nil {
+			return nil, err
+		}
+		return HostPrefixed(inner), nil
+	}
+	return nil, fmt.Errorf("unknown section spec %q", spec)
+}
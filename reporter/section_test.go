@@ -0,0 +1,112 @@
+package reporter
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/mihaichiorean/monidog/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SectionExtractors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	re, err := Regex(`^/api/v\d+/(\w+)`)
+	assert.NoError(t, err)
+	noGroup, err := Regex(`^/\w+`)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		e        SectionExtractor
+		resource string
+		want     string
+	}{
+		{LastSlash(), "/pages/create", "/pages"},
+		{LastSlash(), "/api/v1/users/123", "/api/v1/users"},
+		{Segments(1), "/api/v1/users/123", "/api"},
+		{Segments(2), "/api/v1/users/123?x=1", "/api/v1"},
+		{Segments(3), "/api/", "/api"},
+		{Segments(1), "/", "/"},
+		{re, "/api/v2/users/123", "users"},
+		{re, "/pages/create", OtherSection},
+		{noGroup, "/pages/create", "/pages"},
+		{RouteTemplate(), "/users/123", "/users/{id}"},
+		{RouteTemplate(), "/users/123/posts/", "/users/{id}/posts"},
+		{RouteTemplate(), "/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301", "/orders/{uuid}"},
+		{RouteTemplate(), "/static/a94a8fe5ccb19ba61c4c0873d391e987982fbbd3.js", "/static/a94a8fe5ccb19ba61c4c0873d391e987982fbbd3.js"},
+		{RouteTemplate(), "/blobs/a94a8fe5ccb19ba61c4c0873d391e987982fbbd3", "/blobs/{hash}"},
+		{RouteTemplate(), "/pages/create", "/pages/create"},
+	}
+	for _, c := range cases {
+		l := mocks.NewMockLog(ctrl)
+		l.EXPECT().Resource().Return(c.resource)
+		s, err := c.e.Section(l)
+		assert.NoError(t, err, c.resource)
+		assert.Equal(t, c.want, s, c.resource)
+	}
+
+	_, err = Regex("(")
+	assert.Error(t, err)
+
+	l := mocks.NewMockLog(ctrl)
+	l.EXPECT().Resource().Return("%zz")
+	_, err = Segments(1).Section(l)
+	assert.Error(t, err)
+}
+
+func Test_HostPrefixed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := HostPrefixed(Segments(1))
+	l := mocks.NewMockHTTPLog(ctrl)
+	l.EXPECT().Resource().Return("/api/users")
+	l.EXPECT().VirtualHost().Return("example.com").AnyTimes()
+	s, err := e.Section(l)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com/api", s)
+
+	// no vhost logged
+	h := mocks.NewMockHTTPLog(ctrl)
+	h.EXPECT().Resource().Return("/api/users")
+	h.EXPECT().VirtualHost().Return("")
+	s, err = e.Section(h)
+	assert.NoError(t, err)
+	assert.Equal(t, "/api", s)
+
+	plain := mocks.NewMockLog(ctrl)
+	plain.EXPECT().Resource().Return("/api/users")
+	s, err = e.Section(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "/api", s)
+}
+
+func Test_ParseSectionExtractor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for spec, want := range map[string]string{
+		"":                   "/api/v1/users",
+		"last-slash":         "/api/v1/users",
+		"segments:2":         "/api/v1",
+		"regex:^/api/(v\\d)": "v1",
+		"template":           "/api/v1/users/{id}",
+		"host:segments:1":    "/api",
+	} {
+		e, err := ParseSectionExtractor(spec)
+		if !assert.NoError(t, err, spec) {
+			continue
+		}
+		l := mocks.NewMockLog(ctrl)
+		l.EXPECT().Resource().Return("/api/v1/users/123")
+		s, err := e.Section(l)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, want, s, spec)
+	}
+
+	for _, spec := range []string{"segments", "segments:0", "regex:(", "host:nope", "first-slash"} {
+		_, err := ParseSectionExtractor(spec)
+		assert.Error(t, err, spec)
+	}
+}
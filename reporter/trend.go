@@ -0,0 +1,143 @@
+package reporter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SectionTrend compares the hits of a section with the previous window. Ranks
+// start at 1 and are 0 for windows the section had no hits in
+type SectionTrend struct {
+	Name         string `json:"name"`
+	Hits         int    `json:"hits"`
+	PreviousHits int    `json:"previous_hits"`
+	Delta        int    `json:"delta"`
+	// Change is the percentage Delta is of PreviousHits, 0 for new sections
+	Change       float64 `json:"change"`
+	Rank         int     `json:"rank"`
+	PreviousRank int     `json:"previous_rank"`
+}
+
+// New tells if the section had no hits in the previous window
+func (t SectionTrend) New() bool {
+	return t.PreviousRank == 0
+}
+
+// Vanished tells if the section had no hits in this window
+func (t SectionTrend) Vanished() bool {
+	return t.Rank == 0
+}
+
+// Trend is how a window changed since the previous window of the same size
+type Trend struct {
+	PreviousStart time.Time `json:"previous_start"`
+	PreviousEnd   time.Time `json:"previous_end"`
+	// Sections are in the order of the report, then the vanished ones by their
+	// previous rank
+	Sections []SectionTrend `json:"sections"`
+	New      []string       `json:"new"`
+	Vanished []string       `json:"vanished"`
+	// the previous hot section and the rank the current one had then
+	PreviousHotSection string `json:"previous_hot_section"`
+	HotPreviousRank    int    `json:"hot_previous_rank"`
+}
+
+// Section returns the trend of a section
+func (t Trend) Section(name string) (SectionTrend, bool) {
+	for _, s := range t.Sections {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return SectionTrend{}, false
+}
+
+// Compare returns the trend from the previous window to the current one
+func Compare(prev, cur Report) Trend {
+	t := Trend{
+		PreviousStart:      prev.Start,
+		PreviousEnd:        prev.End,
+		PreviousHotSection: prev.HotSection,
+	}
+	ranks := map[string]int{}
+	for i, s := range prev.Sections {
+		ranks[s.Name] = i + 1
+	}
+	seen := map[string]bool{}
+	for i, s := range cur.Sections {
+		seen[s.Name] = true
+		st := SectionTrend{Name: s.Name, Hits: s.Hits, Rank: i + 1, Delta: s.Hits}
+		if r, ok := ranks[s.Name]; ok {
+			st.PreviousRank = r
+			st.PreviousHits = prev.Sections[r-1].Hits
+			st.Delta = s.Hits - st.PreviousHits
+			st.Change = float64(st.Delta) * 100 / float64(st.PreviousHits)
+		} else {
+			t.New = append(t.New, s.Name)
+		}
+		t.Sections = append(t.Sections, st)
+	}
+	for i, s := range prev.Sections {
+		if seen[s.Name] {
+			continue
+		}
+		t.Vanished = append(t.Vanished, s.Name)
+		t.Sections = append(t.Sections, SectionTrend{
+			Name:         s.Name,
+			PreviousHits: s.Hits,
+			Delta:        -s.Hits,
+			Change:       -100,
+			PreviousRank: i + 1,
+		})
+	}
+	sort.Strings(t.New)
+	sort.Strings(t.Vanished)
+	t.HotPreviousRank = ranks[cur.HotSection]
+	return t
+}
+
+// Copy returns a deep copy of the trend
+func (t *Trend) Copy() *Trend {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	c.Sections = append([]SectionTrend(nil), t.Sections...)
+	c.New = append([]string(nil), t.New...)
+	c.Vanished = append([]string(nil), t.Vanished...)
+	return &c
+}
+
+// formatSectionTrend formats the change of a section, e.g. "+5 +20.0%"
+func formatSectionTrend(t SectionTrend) string {
+	if t.New() {
+		return "new"
+	}
+	return fmt.Sprintf("%+d %+.1f%%", t.Delta, t.Change)
+}
+
+// formatHotTrend describes how the hot section moved, e.g. "up from #3"
+func formatHotTrend(t *Trend, hot string) string {
+	switch {
+	case hot == "":
+		return ""
+	case t.HotPreviousRank == 0:
+		return "new"
+	case t.HotPreviousRank == 1:
+		return "unchanged"
+	}
+	return fmt.Sprintf("up from #%d, was %s", t.HotPreviousRank, t.PreviousHotSection)
+}
+
+// track sets the trend of a report from the previous one of the same window and
+// keeps it for the next one
+func (r *Reporter) track(rep *Report) {
+	if prev, ok := r.previous[rep.Window]; ok && prev.End.Equal(rep.Start) {
+		t := Compare(prev, *rep)
+		rep.Trend = &t
+	}
+	prev := *rep
+	prev.Trend = nil
+	r.previous[rep.Window] = prev
+}
@@ -0,0 +1,69 @@
+package reporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Compare(t *testing.T) {
+	start := time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+	prev := Report{Start: start, End: start.Add(10 * time.Second), HotSection: "/a",
+		Sections: []SectionReport{{Name: "/a", Hits: 10}, {Name: "/b", Hits: 8}, {Name: "/c", Hits: 4}, {Name: "/d", Hits: 1}}}
+	cur := Report{Start: start.Add(10 * time.Second), End: start.Add(20 * time.Second), HotSection: "/c",
+		Sections: []SectionReport{{Name: "/c", Hits: 12}, {Name: "/a", Hits: 5}, {Name: "/e", Hits: 3}, {Name: "/b", Hits: 8}}}
+
+	tr := Compare(prev, cur)
+	assert.Equal(t, prev.Start, tr.PreviousStart)
+	assert.Equal(t, []string{"/e"}, tr.New)
+	assert.Equal(t, []string{"/d"}, tr.Vanished)
+	assert.Equal(t, "/a", tr.PreviousHotSection)
+	assert.Equal(t, 3, tr.HotPreviousRank)
+	assert.Equal(t, []SectionTrend{
+		{Name: "/c", Hits: 12, PreviousHits: 4, Delta: 8, Change: 200, Rank: 1, PreviousRank: 3},
+		{Name: "/a", Hits: 5, PreviousHits: 10, Delta: -5, Change: -50, Rank: 2, PreviousRank: 1},
+		{Name: "/e", Hits: 3, Delta: 3, Rank: 3},
+		{Name: "/b", Hits: 8, PreviousHits: 8, Rank: 4, PreviousRank: 2},
+		{Name: "/d", PreviousHits: 1, Delta: -1, Change: -100, PreviousRank: 4},
+	}, tr.Sections)
+
+	e, _ := tr.Section("/e")
+	assert.True(t, e.New())
+	d, _ := tr.Section("/d")
+	assert.True(t, d.Vanished())
+	c, _ := tr.Section("/c")
+	assert.Equal(t, "+8 +200.0%", formatSectionTrend(c))
+	assert.Equal(t, "new", formatSectionTrend(e))
+	assert.Equal(t, "up from #3, was /a", formatHotTrend(&tr, cur.HotSection))
+}
+
+func Test_track(t *testing.T) {
+	r := NewReporter(10 * time.Second)
+	start := time.Date(2018, 11, 10, 12, 0, 0, 0, time.UTC)
+	first := Report{Start: start, End: start.Add(10 * time.Second), Window: 10 * time.Second, HotSection: "/a", HotHits: 2,
+		Sections: []SectionReport{{Name: "/a", Hits: 2}}}
+	r.track(&first)
+	assert.Nil(t, first.Trend)
+
+	second := Report{Start: first.End, End: first.End.Add(10 * time.Second), Window: 10 * time.Second, HotSection: "/a", HotHits: 3,
+		Sections: []SectionReport{{Name: "/a", Hits: 3}, {Name: "/b", Hits: 1}}}
+	r.track(&second)
+	if assert.NotNil(t, second.Trend) {
+		assert.Equal(t, []string{"/b"}, second.Trend.New)
+	}
+	var out bytes.Buffer
+	PrintReport(&out, second)
+	assert.Contains(t, out.String(), "/a 3 bytes=0 avg=0 errors=0.0% clients=0 urls=0 trend=+1 +50.0%")
+	assert.Contains(t, out.String(), "new sections:  /b")
+	assert.Contains(t, out.String(), "highest hits section:  /a 3 (unchanged)")
+
+	// other windows and gaps don't compare
+	other := Report{Start: first.End, End: first.End.Add(time.Minute), Window: time.Minute}
+	r.track(&other)
+	assert.Nil(t, other.Trend)
+	gap := Report{Start: second.End.Add(10 * time.Second), End: second.End.Add(20 * time.Second), Window: 10 * time.Second}
+	r.track(&gap)
+	assert.Nil(t, gap.Trend)
+}